@@ -0,0 +1,301 @@
+// Package alertmanager implements a receiver of prometheus alertmanager webhook,
+// alerts are rendered into markdown or template card and sent to the robot
+// chosen by the receiver name.
+//
+// see https://prometheus.io/docs/alerting/latest/configuration/#webhook_config
+package alertmanager
+
+import (
+	"bytes"
+	"net/http"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/wjiec/workrobot"
+	"github.com/wjiec/workrobot/internal/strutil"
+	"github.com/wjiec/workrobot/internal/webhook"
+
+	"github.com/pkg/errors"
+)
+
+// ErrMissingLink represents the alert has neither generatorURL nor externalURL,
+// which is required by template card
+var ErrMissingLink = errors.New("alert without generatorURL or externalURL")
+
+// Alert represents an alert of webhook message
+type Alert struct {
+	Status       string            `json:"status"`
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+	StartsAt     time.Time         `json:"startsAt"`
+	EndsAt       time.Time         `json:"endsAt"`
+	GeneratorURL string            `json:"generatorURL"`
+	Fingerprint  string            `json:"fingerprint"`
+}
+
+// Message represents the webhook message of an alert group
+type Message struct {
+	Version           string            `json:"version"`
+	GroupKey          string            `json:"groupKey"`
+	TruncatedAlerts   int               `json:"truncatedAlerts"`
+	Status            string            `json:"status"`
+	Receiver          string            `json:"receiver"`
+	GroupLabels       map[string]string `json:"groupLabels"`
+	CommonLabels      map[string]string `json:"commonLabels"`
+	CommonAnnotations map[string]string `json:"commonAnnotations"`
+	ExternalURL       string            `json:"externalURL"`
+	Alerts            []*Alert          `json:"alerts"`
+}
+
+// Firing returns the firing alerts
+func (m *Message) Firing() []*Alert {
+	return m.filter("firing")
+}
+
+// Resolved returns the resolved alerts
+func (m *Message) Resolved() []*Alert {
+	return m.filter("resolved")
+}
+
+// filter returns the alerts with status
+func (m *Message) filter(status string) (alerts []*Alert) {
+	for _, alert := range m.Alerts {
+		if alert.Status == status {
+			alerts = append(alerts, alert)
+		}
+	}
+	return
+}
+
+// TemplateData represents the data of markdown template, the embedded Message
+// contains the alerts of current message, and Group is the whole alert group
+// when it is split across messages
+type TemplateData struct {
+	*Message
+	Group *Message
+}
+
+// DefaultTemplate is the default markdown template of alert group
+const DefaultTemplate = `{{ if eq .Status "firing" }}<font color="warning">[FIRING:{{ len .Group.Firing }}]</font>{{ else }}<font color="info">[RESOLVED]</font>{{ end }} **{{ index .CommonLabels "alertname" }}**
+{{ range .Alerts }}
+> {{ if eq .Status "firing" }}<font color="warning">firing</font>{{ else }}<font color="info">resolved</font>{{ end }} {{ with index .Annotations "summary" }}{{ . }}{{ else }}{{ index .Labels "alertname" }}{{ end }}
+{{ with index .Annotations "description" }}> {{ . }}
+{{ end }}> {{ labels .Labels }}
+> {{ with .GeneratorURL }}[source]({{ . }}) {{ end }}starts at {{ .StartsAt.Format "2006-01-02 15:04:05" }}
+{{ end }}`
+
+// labels format the labels as sorted key=value pairs
+func labels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, ", ")
+}
+
+// Funcs are the functions available in templates
+var Funcs = template.FuncMap{
+	"labels": labels,
+	"join":   strings.Join,
+	"upper":  strings.ToUpper,
+}
+
+// Format represents the message format of alerts
+type Format int
+
+const (
+	// FormatMarkdown renders alerts into markdown by template
+	FormatMarkdown Format = iota
+	// FormatTemplateCard renders alerts into template card
+	FormatTemplateCard
+)
+
+// alert groups with more alerts are rendered into markdown in the template card
+// format, the robot is limited to 20 messages per minute
+const maxCardAlerts = 5
+
+// Receiver represents a webhook receiver sends alerts to robots
+type Receiver struct {
+	clients  map[string]*workrobot.Client
+	fallback *workrobot.Client
+	format   Format
+	template *template.Template
+}
+
+// ServeHTTP implements http.Handler
+func (r *Receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var msg Message
+	webhook.Serve(w, req, &msg, func() (*workrobot.Client, []workrobot.Messager, error) {
+		client := r.clients[msg.Receiver]
+		if client == nil {
+			client = r.fallback
+		}
+		if client == nil {
+			return nil, nil, webhook.WithStatus(http.StatusNotFound, errors.Errorf("unknown receiver %s", msg.Receiver))
+		}
+
+		messages, err := r.Render(&msg)
+		if errors.Is(err, ErrMissingLink) {
+			return nil, nil, webhook.WithStatus(http.StatusBadRequest, err)
+		}
+		return client, messages, err
+	})
+}
+
+// Render renders the alert group into messages, large groups are split
+// across messages by alerts
+func (r *Receiver) Render(msg *Message) ([]workrobot.Messager, error) {
+	if r.format == FormatTemplateCard {
+		return r.renderCards(msg)
+	}
+	return r.renderMarkdown(msg)
+}
+
+// renderMarkdown renders the alert group into as few markdown messages as possible
+func (r *Receiver) renderMarkdown(msg *Message) ([]workrobot.Messager, error) {
+	var messages []workrobot.Messager
+	var pending []*Alert
+	var last string
+	for _, alert := range msg.Alerts {
+		content, err := r.execute(msg, append(pending, alert))
+		if err != nil {
+			return nil, err
+		}
+
+		if len(content) <= workrobot.MarkdownMessageMaxLength || len(pending) == 0 {
+			pending, last = append(pending, alert), content
+			continue
+		}
+
+		if messages, err = appendMarkdown(messages, last); err != nil {
+			return nil, err
+		}
+		if last, err = r.execute(msg, []*Alert{alert}); err != nil {
+			return nil, err
+		}
+		pending = []*Alert{alert}
+	}
+
+	if len(pending) == 0 {
+		content, err := r.execute(msg, nil)
+		if err != nil {
+			return nil, err
+		}
+		last = content
+	}
+	return appendMarkdown(messages, last)
+}
+
+// execute renders the template with the alerts of group
+func (r *Receiver) execute(msg *Message, alerts []*Alert) (string, error) {
+	data := *msg
+	data.Alerts = alerts
+
+	var buf bytes.Buffer
+	if err := r.template.Execute(&buf, &TemplateData{Message: &data, Group: msg}); err != nil {
+		return "", errors.Wrap(err, "cannot render template")
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// appendMarkdown append content as markdown message, it is truncated
+// when a single alert is too long
+func appendMarkdown(messages []workrobot.Messager, content string) ([]workrobot.Messager, error) {
+	var markdown workrobot.Markdown
+	if err := markdown.RawContent(strutil.Truncate(content, workrobot.MarkdownMessageMaxLength)); err != nil {
+		return nil, err
+	}
+	return append(messages, &markdown), nil
+}
+
+// renderCards renders each alert into a template card, or falls back to
+// markdown when the group has more than maxCardAlerts alerts
+func (r *Receiver) renderCards(msg *Message) ([]workrobot.Messager, error) {
+	if len(msg.Alerts) > maxCardAlerts {
+		return r.renderMarkdown(msg)
+	}
+
+	var messages []workrobot.Messager
+	for _, alert := range msg.Alerts {
+		link := strutil.FirstOf(alert.GeneratorURL, msg.ExternalURL)
+		if link == "" {
+			return nil, ErrMissingLink
+		}
+
+		title := strutil.TruncateRunes("["+strings.ToUpper(alert.Status)+"] "+alert.Labels["alertname"], workrobot.CardTitleMaxLength)
+		description := strutil.FirstOf(alert.Annotations["summary"], alert.Annotations["description"])
+		card, err := workrobot.NewTemplateCard(title, strutil.TruncateRunes(description, workrobot.CardDescriptionMaxLength), link)
+		if err != nil {
+			return nil, err
+		}
+
+		card.SubTitle = strutil.TruncateRunes(alert.Annotations["description"], workrobot.CardSubTitleMaxLength)
+		keys := make([]string, 0, len(alert.Labels))
+		for k := range alert.Labels {
+			if k != "alertname" {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			if card.AddField(&workrobot.CardField{Key: k, Value: alert.Labels[k]}) != nil {
+				break
+			}
+		}
+		if msg.ExternalURL != "" {
+			_ = card.AddJump(&workrobot.CardJump{Title: "Alertmanager", Url: msg.ExternalURL})
+		}
+
+		messages = append(messages, card)
+	}
+	return messages, nil
+}
+
+// Option represents additional receiver configuration
+type Option func(*Receiver) error
+
+// WithTemplate override the markdown template, the template is executed with
+// a TemplateData contains part of alerts, and Funcs are available
+func WithTemplate(text string) Option {
+	return func(r *Receiver) error {
+		tmpl, err := template.New("alertmanager").Funcs(Funcs).Parse(text)
+		if err != nil {
+			return errors.Wrap(err, "invalid template")
+		}
+
+		r.template = tmpl
+		return nil
+	}
+}
+
+// WithFormat sets the message format of alerts
+func WithFormat(format Format) Option {
+	return func(r *Receiver) error {
+		r.format = format
+		return nil
+	}
+}
+
+// WithFallback sets the robot of receivers not in clients
+func WithFallback(client *workrobot.Client) Option {
+	return func(r *Receiver) error {
+		r.fallback = client
+		return nil
+	}
+}
+
+// New create a receiver sends alerts to clients by the receiver name
+func New(clients map[string]*workrobot.Client, options ...Option) (*Receiver, error) {
+	r := &Receiver{clients: clients, format: FormatMarkdown}
+	for _, opt := range append([]Option{WithTemplate(DefaultTemplate)}, options...) {
+		if err := opt(r); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
@@ -0,0 +1,131 @@
+package alertmanager
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/wjiec/workrobot"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func loadMessage(t *testing.T) *Message {
+	bs, err := ioutil.ReadFile("testdata/webhook.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var msg Message
+	if err := json.Unmarshal(bs, &msg); err != nil {
+		t.Fatal(err)
+	}
+	return &msg
+}
+
+func TestReceiver_Render(t *testing.T) {
+	r, err := New(nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	msg := loadMessage(t)
+	messages, err := r.Render(msg)
+	if assert.NoError(t, err) && assert.Len(t, messages, 1) {
+		content := string(messages[0].Message())
+		assert.Contains(t, content, "[FIRING:1]")
+		assert.Contains(t, content, "latency above 1s")
+		assert.Contains(t, content, "instance=10.2.3.4:9100")
+	}
+
+	for i := 0; i < 6; i++ {
+		alert := *msg.Alerts[0]
+		alert.Annotations = map[string]string{"description": strings.Repeat("x", 1000)}
+		msg.Alerts = append(msg.Alerts, &alert)
+	}
+	messages, err = r.Render(msg)
+	if assert.NoError(t, err) && assert.Len(t, messages, 3) {
+		for _, message := range messages {
+			assert.Contains(t, string(message.Message()), "[FIRING:7]")
+		}
+	}
+}
+
+func TestReceiver_RenderCards(t *testing.T) {
+	r, _ := New(nil, WithFormat(FormatTemplateCard))
+
+	messages, err := r.Render(loadMessage(t))
+	if assert.NoError(t, err) && assert.Len(t, messages, 2) {
+		assert.Equal(t, "template_card", workrobot.MessageType(messages[0]))
+		assert.Contains(t, string(messages[0].Message()), "[FIRING] HighLatency")
+		assert.Contains(t, string(messages[1].Message()), "[RESOLVED] HighLatency")
+	}
+}
+
+func TestReceiver_RenderCardsLimit(t *testing.T) {
+	r, _ := New(nil, WithFormat(FormatTemplateCard))
+
+	msg := loadMessage(t)
+	msg.Alerts[0].Labels["alertname"] = strings.Repeat("告警", 20)
+	msg.Alerts[0].Annotations["summary"] = strings.Repeat("x", 100)
+	messages, err := r.Render(msg)
+	if assert.NoError(t, err) && assert.Len(t, messages, 2) {
+		content := string(messages[0].Message())
+		assert.Contains(t, content, `"title":"[FIRING] `+strings.Repeat("告警", 7)+`..."`)
+		assert.Contains(t, content, `"desc":"`+strings.Repeat("x", 27)+`..."`)
+	}
+
+	for i := 0; i < maxCardAlerts; i++ {
+		msg.Alerts = append(msg.Alerts, msg.Alerts[0])
+	}
+	messages, err = r.Render(msg)
+	if assert.NoError(t, err) && assert.Len(t, messages, 1) {
+		assert.Equal(t, "markdown", workrobot.MessageType(messages[0]))
+	}
+}
+
+func TestReceiver_ServeHTTP(t *testing.T) {
+	var received []string
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bs, _ := ioutil.ReadAll(r.Body)
+		received = append(received, string(bs))
+		_, _ = fmt.Fprint(w, `{"errcode":0,"errmsg":"ok"}`)
+	}))
+	defer gateway.Close()
+
+	ops, _ := workrobot.NewClient("", workrobot.WithWebhook(gateway.URL))
+	r, _ := New(map[string]*workrobot.Client{"ops": ops}, WithTemplate(`{{ .Status }}: {{ len .Alerts }} alerts`))
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	f, _ := os.Open("testdata/webhook.json")
+	defer func() { _ = f.Close() }()
+
+	resp, err := http.Post(server.URL, "application/json", f)
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Contains(t, received[0], "firing: 2 alerts")
+	}
+
+	resp, err = http.Post(server.URL, "application/json", strings.NewReader(`{"receiver":"unknown"}`))
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	}
+
+	cards, _ := New(map[string]*workrobot.Client{"ops": ops}, WithFormat(FormatTemplateCard))
+	server = httptest.NewServer(cards)
+	defer server.Close()
+
+	resp, err = http.Post(server.URL, "application/json", strings.NewReader(`{"receiver":"ops","alerts":[{"status":"firing"}]}`))
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	}
+
+	_, err = New(nil, WithTemplate("{{ .Unclosed"))
+	assert.Error(t, err)
+}
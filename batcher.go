@@ -0,0 +1,192 @@
+package workrobot
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/multierr"
+)
+
+// batchGroup represents the pending messages of a key
+type batchGroup struct {
+	key      string
+	size     int
+	messages []Messager
+}
+
+// Batcher collects text and markdown messages over a window and sends them
+// as few markdown messages as possible, messages are grouped by the key and
+// never merged across groups
+//
+// the member mentions of text messages are converted into <@member>, and texts
+// with mobile or all mentions, as well as other messages, are sent unchanged
+type Batcher struct {
+	client *Client
+	window time.Duration
+
+	mu     sync.Mutex
+	timer  *time.Timer
+	groups []*batchGroup
+
+	sending sync.Mutex
+	onError func(error)
+}
+
+// Add append a message to the group of key, the group is flushed immediately
+// when its content reaches MarkdownMessageMaxLength
+func (b *Batcher) Add(key string, msg Messager) error {
+	b.mu.Lock()
+	group := b.group(key)
+	group.messages = append(group.messages, msg)
+	group.size += len(batchContent(msg)) + 1
+
+	if group.size < MarkdownMessageMaxLength {
+		if b.timer == nil {
+			b.timer = time.AfterFunc(b.window, b.flushTimer)
+		}
+		b.mu.Unlock()
+		return nil
+	}
+
+	b.removeGroup(group)
+	b.mu.Unlock()
+
+	return b.send(context.Background(), []*batchGroup{group})
+}
+
+// Flush sends all pending messages immediately
+func (b *Batcher) Flush() error {
+	return b.FlushContext(context.Background())
+}
+
+// FlushContext sends all pending messages immediately with the context
+func (b *Batcher) FlushContext(ctx context.Context) error {
+	b.mu.Lock()
+	groups := b.groups
+	b.groups = nil
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	b.mu.Unlock()
+
+	return b.send(ctx, groups)
+}
+
+// Close flush all pending messages, it is same as Flush
+func (b *Batcher) Close() error {
+	return b.Flush()
+}
+
+// flushTimer flush the pending messages when window closed
+func (b *Batcher) flushTimer() {
+	if err := b.Flush(); err != nil && b.onError != nil {
+		b.onError(err)
+	}
+}
+
+// group returns the pending group of key, create it if not exists
+func (b *Batcher) group(key string) *batchGroup {
+	for _, group := range b.groups {
+		if group.key == key {
+			return group
+		}
+	}
+
+	group := &batchGroup{key: key}
+	b.groups = append(b.groups, group)
+	return group
+}
+
+// removeGroup removes the group from pending groups
+func (b *Batcher) removeGroup(target *batchGroup) {
+	for i, group := range b.groups {
+		if group == target {
+			b.groups = append(b.groups[:i], b.groups[i+1:]...)
+			return
+		}
+	}
+}
+
+// send merge and send messages of groups in order, the rest messages
+// of a group are skipped when error occurs
+func (b *Batcher) send(ctx context.Context, groups []*batchGroup) (err error) {
+	b.sending.Lock()
+	defer b.sending.Unlock()
+
+	for _, group := range groups {
+		err = multierr.Append(err, b.client.SendContext(ctx, Coalesce(group.messages...)...))
+	}
+	return
+}
+
+// Coalesce merge adjacent text and markdown messages into as few markdown
+// messages as MarkdownMessageMaxLength allows, and keeps the order of messages
+func Coalesce(messages ...Messager) []Messager {
+	var results []Messager
+	var current *Markdown
+	for _, msg := range messages {
+		content := batchContent(msg)
+		if content == "" {
+			current = nil
+			results = append(results, msg)
+			continue
+		}
+
+		if current != nil && current.AddLine(content) == nil {
+			continue
+		}
+
+		current = &Markdown{}
+		if current.AddLine(content) != nil {
+			current = nil
+			results = append(results, msg)
+			continue
+		}
+		results = append(results, current)
+	}
+
+	return results
+}
+
+// batchContent returns the markdown content of message, or empty
+// when the message cannot be merged
+func batchContent(msg Messager) string {
+	switch m := msg.(type) {
+	case *Text:
+		if m.all || len(m.mobiles) != 0 {
+			return ""
+		}
+
+		content := m.content
+		for _, member := range m.members {
+			content += " <@" + member + ">"
+		}
+		return content
+	case *Markdown:
+		return strings.Join(m.lines, "\n")
+	}
+	return ""
+}
+
+// BatcherOption represents additional batcher configuration
+type BatcherOption func(*Batcher)
+
+// WithBatchErrorHandler sets the handler of errors when window closed
+// and messages are flushed in background
+func WithBatchErrorHandler(fn func(error)) BatcherOption {
+	return func(b *Batcher) {
+		b.onError = fn
+	}
+}
+
+// NewBatcher create a batcher flushes messages to client every window
+func NewBatcher(client *Client, window time.Duration, options ...BatcherOption) *Batcher {
+	b := &Batcher{client: client, window: window}
+	for _, opt := range options {
+		opt(b)
+	}
+	return b
+}
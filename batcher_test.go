@@ -0,0 +1,83 @@
+package workrobot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCoalesce(t *testing.T) {
+	first, _ := NewText("first")
+	first.MentionMember("jayson")
+	second, _ := NewMarkdown("second", "third")
+	all, _ := NewText("all")
+	all.MentionAll(true)
+	last, _ := NewText("last")
+
+	results := Coalesce(first, second, all, last, NewMedia("media-id"))
+	if assert.Len(t, results, 4) {
+		assert.Equal(t, []string{"first <@jayson>", "second\nthird"}, results[0].(*Markdown).lines)
+		assert.Equal(t, all, results[1])
+		assert.Equal(t, []string{"last"}, results[2].(*Markdown).lines)
+	}
+
+	var long []Messager
+	for i := 0; i < 5; i++ {
+		msg, _ := NewText(strings.Repeat("x", TextMessageMaxLength))
+		long = append(long, msg)
+	}
+	assert.Len(t, Coalesce(long...), 5)
+}
+
+func TestBatcher(t *testing.T) {
+	var mu sync.Mutex
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var data payload
+		bs, _ := ioutil.ReadAll(r.Body)
+		_ = json.Unmarshal(bs, &data)
+
+		mu.Lock()
+		received = append(received, data.Markdown.Content)
+		mu.Unlock()
+		_, _ = fmt.Fprint(w, `{"errcode":0,"errmsg":"ok"}`)
+	}))
+	defer server.Close()
+
+	c, _ := NewClient("", WithWebhook(server.URL))
+	b := NewBatcher(c, 50*time.Millisecond)
+
+	for i := 0; i < 3; i++ {
+		msg, _ := NewText(fmt.Sprintf("host-%d deployed", i))
+		assert.NoError(t, b.Add("deploy", msg))
+	}
+	alert, _ := NewText("disk full")
+	assert.NoError(t, b.Add("alert", alert))
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(received) == 2
+	}, time.Second, 10*time.Millisecond)
+
+	mu.Lock()
+	assert.Equal(t, []string{"host-0 deployed\nhost-1 deployed\nhost-2 deployed", "disk full"}, received)
+	mu.Unlock()
+
+	big, _ := NewText(strings.Repeat("x", TextMessageMaxLength))
+	assert.NoError(t, b.Add("big", big))
+	assert.NoError(t, b.Add("big", big))
+	mu.Lock()
+	assert.Len(t, received, 4)
+	mu.Unlock()
+
+	assert.NoError(t, b.Close())
+}
@@ -0,0 +1,284 @@
+// Package callback implements the callback interface of robots, which receives
+// the messages when users mention the robot in chats.
+//
+// Requests are verified by msg_signature and decrypted by the EncodingAESKey,
+// the reply of handler is encrypted and sent back synchronously.
+//
+// see https://developer.work.weixin.qq.com/document/path/99399
+package callback
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wjiec/workrobot"
+
+	"github.com/pkg/errors"
+)
+
+// max size of callback request body
+const maxRequestSize = 1024 * 1024
+
+// DefaultTimestampWindow is the max difference between the request timestamp
+// and the local time
+const DefaultTimestampWindow = 5 * time.Minute
+
+// ErrExpiredTimestamp represents the request timestamp is out of the window,
+// which may be a replayed request
+var ErrExpiredTimestamp = errors.New("timestamp out of window")
+
+// types of inbound message
+const (
+	MessageTypeText       = "text"
+	MessageTypeEvent      = "event"
+	MessageTypeAttachment = "attachment"
+)
+
+// User represents the sender of message
+type User struct {
+	UserId string `xml:"UserId" json:"userid"`
+	Name   string `xml:"Name" json:"name"`
+	Alias  string `xml:"Alias" json:"alias"`
+}
+
+// Text represents the content of text message
+type Text struct {
+	Content string `xml:"Content" json:"content"`
+}
+
+// Event represents the event of chat, like the robot added to chat
+type Event struct {
+	EventType string `xml:"EventType" json:"eventtype"`
+}
+
+// Action represents the clicked button of attachment
+type Action struct {
+	Name  string `xml:"Name" json:"name"`
+	Value string `xml:"Value" json:"value"`
+	Type  string `xml:"Type" json:"type"`
+}
+
+// Attachment represents the click of interactive message
+type Attachment struct {
+	CallbackId string  `xml:"CallbackId" json:"callbackid"`
+	Actions    *Action `xml:"Actions" json:"actions"`
+}
+
+// Message represents an inbound message of callback
+type Message struct {
+	WebhookUrl     string      `xml:"WebhookUrl" json:"webhookurl"`
+	ChatId         string      `xml:"ChatId" json:"chatid"`
+	PostId         string      `xml:"PostId" json:"postid"`
+	ChatType       string      `xml:"ChatType" json:"chattype"`
+	GetChatInfoUrl string      `xml:"GetChatInfoUrl" json:"getchatinfourl"`
+	From           *User       `xml:"From" json:"from"`
+	MessageType    string      `xml:"MsgType" json:"msgtype"`
+	Text           *Text       `xml:"Text" json:"text"`
+	Event          *Event      `xml:"Event" json:"event"`
+	Attachment     *Attachment `xml:"Attachment" json:"attachment"`
+	MessageId      string      `xml:"MsgId" json:"msgid"`
+}
+
+// Handler represents the handler of inbound messages, the returned message
+// is replied synchronously, and nothing replied when it is nil
+type Handler func(ctx context.Context, msg *Message) (workrobot.Messager, error)
+
+// envelope represents the encrypted body of request and response
+type envelope struct {
+	XMLName   xml.Name `xml:"xml" json:"-"`
+	Encrypt   string   `xml:"Encrypt" json:"encrypt"`
+	Signature string   `xml:"MsgSignature,omitempty" json:"msgsignature,omitempty"`
+	Timestamp string   `xml:"TimeStamp,omitempty" json:"timestamp,omitempty"`
+	Nonce     string   `xml:"Nonce,omitempty" json:"nonce,omitempty"`
+}
+
+// Server represents the http handler of callback interface
+type Server struct {
+	crypter *Crypter
+	handler Handler
+	window  time.Duration
+}
+
+// ServeHTTP implements http.Handler, GET requests verify the callback url
+// and POST requests deliver messages
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	signature, timestamp, nonce := query.Get("msg_signature"), query.Get("timestamp"), query.Get("nonce")
+
+	switch r.Method {
+	case http.MethodGet:
+		echo := query.Get("echostr")
+		if err := s.verify(signature, timestamp, nonce, echo); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		plaintext, err := s.crypter.Decrypt(echo)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write(plaintext)
+	case http.MethodPost:
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestSize))
+		if err != nil {
+			http.Error(w, "request body unreadable", http.StatusBadRequest)
+			return
+		}
+
+		isJSON := isJSON(body)
+		var req envelope
+		if isJSON {
+			err = json.Unmarshal(body, &req)
+		} else {
+			err = xml.Unmarshal(body, &req)
+		}
+		if err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		if err := s.verify(signature, timestamp, nonce, req.Encrypt); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		msg, err := s.Decode(req.Encrypt)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		reply, err := s.handler(r.Context(), msg)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if reply == nil {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		resp, err := s.encode(reply)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if isJSON {
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(resp)
+		} else {
+			w.Header().Set("Content-Type", "application/xml")
+			_ = xml.NewEncoder(w).Encode(resp)
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// verify checks the signature of request and the timestamp is in the window
+func (s *Server) verify(signature, timestamp, nonce, encrypted string) error {
+	if !s.crypter.Verify(signature, timestamp, nonce, encrypted) {
+		return ErrInvalidSignature
+	}
+	if s.window <= 0 {
+		return nil
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrExpiredTimestamp
+	}
+	if diff := time.Since(time.Unix(unix, 0)); diff > s.window || diff < -s.window {
+		return ErrExpiredTimestamp
+	}
+	return nil
+}
+
+// Decode decrypts and parses the inbound message in xml or json
+func (s *Server) Decode(encrypted string) (*Message, error) {
+	plaintext, err := s.crypter.Decrypt(encrypted)
+	if err != nil {
+		return nil, err
+	}
+
+	var msg Message
+	if isJSON(plaintext) {
+		err = json.Unmarshal(plaintext, &msg)
+	} else {
+		err = xml.Unmarshal(plaintext, &msg)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid message")
+	}
+	return &msg, nil
+}
+
+// encode encrypts the reply message into the signed envelope
+func (s *Server) encode(reply workrobot.Messager) (*envelope, error) {
+	encrypted, err := s.crypter.Encrypt(reply.Message())
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, 8)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, errors.Wrap(err, "cannot generate nonce")
+	}
+
+	resp := &envelope{Encrypt: encrypted, Nonce: hex.EncodeToString(nonce)}
+	resp.Timestamp = strconv.FormatInt(time.Now().Unix(), 10)
+	resp.Signature = s.crypter.Signature(resp.Timestamp, resp.Nonce, resp.Encrypt)
+	return resp, nil
+}
+
+// isJSON checks whether the body is a json object
+func isJSON(body []byte) bool {
+	return strings.HasPrefix(strings.TrimSpace(string(body)), "{")
+}
+
+// Option represents additional server configuration
+type Option func(*Server)
+
+// WithReceiveId checks the receive id of decrypted messages
+func WithReceiveId(receiveId string) Option {
+	return func(s *Server) {
+		s.crypter.receiveId = receiveId
+	}
+}
+
+// WithTimestampWindow sets the max difference between the request timestamp
+// and the local time, the timestamp is not checked when it is not positive
+func WithTimestampWindow(window time.Duration) Option {
+	return func(s *Server) {
+		s.window = window
+	}
+}
+
+// New create a callback server by the token and EncodingAESKey of robot
+func New(token, encodingAESKey string, handler Handler, options ...Option) (*Server, error) {
+	if handler == nil {
+		return nil, errors.New("handler required")
+	}
+
+	crypter, err := NewCrypter(token, encodingAESKey, "")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{crypter: crypter, handler: handler, window: DefaultTimestampWindow}
+	for _, opt := range options {
+		opt(s)
+	}
+	return s, nil
+}
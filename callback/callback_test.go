@@ -0,0 +1,161 @@
+package callback
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wjiec/workrobot"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testToken          = "QDG6eK"
+	testEncodingAESKey = "jWmYm7qr5nMoAUwZRjGtBxmz3KA1tkAj3ykkR6q2B2C"
+)
+
+const testMessage = `<xml>
+<WebhookUrl><![CDATA[https://qyapi.weixin.qq.com/cgi-bin/webhook/send?key=xxx]]></WebhookUrl>
+<ChatId><![CDATA[wrkSFfCgAAxxxxx]]></ChatId>
+<ChatType>group</ChatType>
+<From><UserId>zhangsan</UserId><Name><![CDATA[Zhang San]]></Name><Alias><![CDATA[jackzhang]]></Alias></From>
+<MsgType>text</MsgType>
+<Text><Content><![CDATA[@RobotA hello robot]]></Content></Text>
+<MsgId>abcdabcdabcd</MsgId>
+</xml>`
+
+func TestCrypter(t *testing.T) {
+	crypter, err := NewCrypter(testToken, testEncodingAESKey, "robot")
+	if assert.NoError(t, err) {
+		encrypted, err := crypter.Encrypt([]byte("hello world"))
+		if assert.NoError(t, err) {
+			plaintext, err := crypter.Decrypt(encrypted)
+			if assert.NoError(t, err) {
+				assert.Equal(t, "hello world", string(plaintext))
+			}
+		}
+
+		signature := crypter.Signature("1409659813", "1372623149", encrypted)
+		assert.True(t, crypter.Verify(signature, "1409659813", "1372623149", encrypted))
+		assert.False(t, crypter.Verify(signature, "1409659814", "1372623149", encrypted))
+
+		other, _ := NewCrypter(testToken, testEncodingAESKey, "other")
+		_, err = other.Decrypt(encrypted)
+		assert.Error(t, err)
+
+		_, err = crypter.Decrypt("aGVsbG8=")
+		assert.Equal(t, ErrInvalidCiphertext, err)
+	}
+
+	_, err = NewCrypter(testToken, "short", "")
+	assert.Error(t, err)
+}
+
+func TestServer_ServeHTTP(t *testing.T) {
+	var received *Message
+	handler := func(ctx context.Context, msg *Message) (workrobot.Messager, error) {
+		received = msg
+		return workrobot.NewText("pong")
+	}
+
+	s, err := New(testToken, testEncodingAESKey, handler)
+	if !assert.NoError(t, err) {
+		return
+	}
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	crypter, _ := NewCrypter(testToken, testEncodingAESKey, "")
+	queryAt := func(encrypted string, at time.Time) url.Values {
+		timestamp := strconv.FormatInt(at.Unix(), 10)
+		return url.Values{
+			"msg_signature": {crypter.Signature(timestamp, "nonce", encrypted)},
+			"timestamp":     {timestamp},
+			"nonce":         {"nonce"},
+		}
+	}
+	query := func(encrypted string) url.Values {
+		return queryAt(encrypted, time.Now())
+	}
+
+	t.Run("verify url", func(t *testing.T) {
+		echo, _ := crypter.Encrypt([]byte("echo"))
+		params := query(echo)
+		params.Set("echostr", echo)
+
+		resp, err := http.Get(server.URL + "?" + params.Encode())
+		if assert.NoError(t, err) {
+			body, _ := ioutil.ReadAll(resp.Body)
+			assert.Equal(t, "echo", string(body))
+		}
+
+		params.Set("msg_signature", "invalid")
+		resp, err = http.Get(server.URL + "?" + params.Encode())
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		}
+	})
+
+	t.Run("xml message", func(t *testing.T) {
+		encrypted, _ := crypter.Encrypt([]byte(testMessage))
+		body := fmt.Sprintf("<xml><Encrypt><![CDATA[%s]]></Encrypt></xml>", encrypted)
+
+		resp, err := http.Post(server.URL+"?"+query(encrypted).Encode(), "text/xml", strings.NewReader(body))
+		if assert.NoError(t, err) && assert.Equal(t, http.StatusOK, resp.StatusCode) {
+			assert.Equal(t, "zhangsan", received.From.UserId)
+			assert.Equal(t, MessageTypeText, received.MessageType)
+			assert.Equal(t, "@RobotA hello robot", received.Text.Content)
+
+			var reply envelope
+			if assert.NoError(t, xml.NewDecoder(resp.Body).Decode(&reply)) {
+				assert.True(t, crypter.Verify(reply.Signature, reply.Timestamp, reply.Nonce, reply.Encrypt))
+
+				plaintext, _ := crypter.Decrypt(reply.Encrypt)
+				assert.Contains(t, string(plaintext), `"text":{"content":"pong"}`)
+			}
+		}
+	})
+
+	t.Run("expired timestamp", func(t *testing.T) {
+		encrypted, _ := crypter.Encrypt([]byte(testMessage))
+		body := fmt.Sprintf("<xml><Encrypt><![CDATA[%s]]></Encrypt></xml>", encrypted)
+
+		for _, at := range []time.Time{time.Now().Add(-time.Hour), time.Now().Add(time.Hour)} {
+			resp, err := http.Post(server.URL+"?"+queryAt(encrypted, at).Encode(), "text/xml", strings.NewReader(body))
+			if assert.NoError(t, err) {
+				assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+			}
+		}
+
+		disabled, _ := New(testToken, testEncodingAESKey, handler, WithTimestampWindow(0))
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/?"+queryAt(encrypted, time.Unix(1409659813, 0)).Encode(), strings.NewReader(body))
+		disabled.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("json message", func(t *testing.T) {
+		message := `{"msgtype":"event","chatid":"wrkSFfCgAAxxxxx","from":{"userid":"lisi"},"event":{"eventtype":"add_to_chat"}}`
+		encrypted, _ := crypter.Encrypt([]byte(message))
+		body, _ := json.Marshal(map[string]string{"encrypt": encrypted})
+
+		resp, err := http.Post(server.URL+"?"+query(encrypted).Encode(), "application/json", strings.NewReader(string(body)))
+		if assert.NoError(t, err) && assert.Equal(t, http.StatusOK, resp.StatusCode) {
+			assert.Equal(t, MessageTypeEvent, received.MessageType)
+			assert.Equal(t, "add_to_chat", received.Event.EventType)
+
+			var reply envelope
+			assert.NoError(t, json.NewDecoder(resp.Body).Decode(&reply))
+		}
+	})
+}
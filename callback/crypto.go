@@ -0,0 +1,132 @@
+package callback
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// block size of pkcs#7 padding used by wecom
+const paddingBlockSize = 32
+
+var (
+	// ErrInvalidSignature represents the msg_signature mismatched
+	ErrInvalidSignature = errors.New("invalid signature")
+	// ErrInvalidCiphertext represents the encrypted message is malformed
+	ErrInvalidCiphertext = errors.New("invalid ciphertext")
+)
+
+// Crypter represents the signer and cipher of callback messages
+type Crypter struct {
+	token     string
+	key       []byte
+	receiveId string
+}
+
+// Signature returns the msg_signature of encrypted message
+func (c *Crypter) Signature(timestamp, nonce, encrypted string) string {
+	parts := []string{c.token, timestamp, nonce, encrypted}
+	sort.Strings(parts)
+
+	sum := sha1.Sum([]byte(strings.Join(parts, "")))
+	return hex.EncodeToString(sum[:])
+}
+
+// Verify checks the msg_signature of encrypted message
+func (c *Crypter) Verify(signature, timestamp, nonce, encrypted string) bool {
+	expected := c.Signature(timestamp, nonce, encrypted)
+	return subtle.ConstantTimeCompare([]byte(signature), []byte(expected)) == 1
+}
+
+// Decrypt decrypts the base64 encoded message, the receive id is checked
+// when it is configured
+func (c *Crypter) Decrypt(encrypted string) ([]byte, error) {
+	ciphertext, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid base64 ciphertext")
+	}
+	if len(ciphertext) == 0 || len(ciphertext)%aes.BlockSize != 0 {
+		return nil, ErrInvalidCiphertext
+	}
+
+	block, err := aes.NewCipher(c.key)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, c.key[:aes.BlockSize]).CryptBlocks(plaintext, ciphertext)
+
+	// random(16) + length(4) + message + receive id + padding
+	padding := int(plaintext[len(plaintext)-1])
+	if padding < 1 || padding > paddingBlockSize || padding > len(plaintext) {
+		return nil, ErrInvalidCiphertext
+	}
+	plaintext = plaintext[:len(plaintext)-padding]
+	if len(plaintext) < 20 {
+		return nil, ErrInvalidCiphertext
+	}
+
+	size := int(binary.BigEndian.Uint32(plaintext[16:20]))
+	if size > len(plaintext)-20 {
+		return nil, ErrInvalidCiphertext
+	}
+
+	message, receiveId := plaintext[20:20+size], string(plaintext[20+size:])
+	if c.receiveId != "" && receiveId != c.receiveId {
+		return nil, errors.Errorf("unexpected receive id %q", receiveId)
+	}
+	return message, nil
+}
+
+// Encrypt encrypts the message into base64 encoded ciphertext
+func (c *Crypter) Encrypt(message []byte) (string, error) {
+	var buf bytes.Buffer
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", errors.Wrap(err, "cannot generate random")
+	}
+	buf.Write(random)
+
+	var size [4]byte
+	binary.BigEndian.PutUint32(size[:], uint32(len(message)))
+	buf.Write(size[:])
+	buf.Write(message)
+	buf.WriteString(c.receiveId)
+
+	padding := paddingBlockSize - buf.Len()%paddingBlockSize
+	buf.Write(bytes.Repeat([]byte{byte(padding)}, padding))
+
+	block, err := aes.NewCipher(c.key)
+	if err != nil {
+		return "", err
+	}
+
+	ciphertext := make([]byte, buf.Len())
+	cipher.NewCBCEncrypter(block, c.key[:aes.BlockSize]).CryptBlocks(ciphertext, buf.Bytes())
+	return base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+// NewCrypter create a crypter by the token and 43 characters EncodingAESKey
+// of callback configuration
+func NewCrypter(token, encodingAESKey, receiveId string) (*Crypter, error) {
+	if len(encodingAESKey) != 43 {
+		return nil, errors.New("EncodingAESKey must be 43 characters")
+	}
+
+	key, err := base64.StdEncoding.DecodeString(encodingAESKey + "=")
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid EncodingAESKey")
+	}
+	return &Crypter{token: token, key: key, receiveId: receiveId}, nil
+}
@@ -0,0 +1,326 @@
+// Package chatops implements a command router on top of robot callbacks,
+// commands like "/deploy api prod" sent by mentioning the robot are parsed,
+// checked by permissions and dispatched to handlers, the result of handler
+// is sent back via the webhook of originating chat.
+package chatops
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/wjiec/workrobot"
+	"github.com/wjiec/workrobot/callback"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultTimeout is the max duration of running a command
+const DefaultTimeout = 5 * time.Minute
+
+// ErrMissingWebhook represents the callback message has no webhook to reply
+var ErrMissingWebhook = errors.New("webhook url of message required")
+
+// Request represents a parsed command invocation
+type Request struct {
+	Message *callback.Message
+	Command *Command
+	Args    []string
+}
+
+// Arg returns the value of named argument, or empty when it is not given
+func (r *Request) Arg(name string) string {
+	for i, arg := range r.Command.Args {
+		if strings.Trim(arg, "[]") == name && i < len(r.Args) {
+			return r.Args[i]
+		}
+	}
+	return ""
+}
+
+// UserId returns the user id of sender
+func (r *Request) UserId() string {
+	if r.Message.From == nil {
+		return ""
+	}
+	return r.Message.From.UserId
+}
+
+// HandlerFunc represents the handler of command, the returned message is sent
+// back to the chat, and nothing is sent when it is nil
+type HandlerFunc func(ctx context.Context, req *Request) (workrobot.Messager, error)
+
+// Command represents a chat command
+type Command struct {
+	// Name is the command name without prefix
+	Name string
+	// Args are the argument names, the optional ones are wrapped in brackets
+	// like [tag], and extra arguments are kept in Request.Args
+	Args []string
+	// Description shows in the help text
+	Description string
+	// Users are the user ids allowed to run the command, empty allows everyone
+	Users []string
+	// Handler handles the command
+	Handler HandlerFunc
+}
+
+// Usage returns the usage of command
+func (c *Command) Usage(prefix string) string {
+	parts := []string{prefix + c.Name}
+	for _, arg := range c.Args {
+		if !strings.HasPrefix(arg, "[") {
+			arg = "<" + arg + ">"
+		}
+		parts = append(parts, arg)
+	}
+	return strings.Join(parts, " ")
+}
+
+// required returns the count of required arguments
+func (c *Command) required() (n int) {
+	for _, arg := range c.Args {
+		if !strings.HasPrefix(arg, "[") {
+			n++
+		}
+	}
+	return
+}
+
+// permitted checks whether the user can run the command
+func (c *Command) permitted(userId string) bool {
+	if len(c.Users) == 0 {
+		return true
+	}
+
+	for _, user := range c.Users {
+		if user == userId {
+			return true
+		}
+	}
+	return false
+}
+
+// Router represents a command router handles callback messages
+type Router struct {
+	prefix        string
+	commands      map[string]*Command
+	clientOptions []workrobot.ClientOption
+	errorHandler  func(error)
+	timeout       time.Duration
+	wg            sync.WaitGroup
+}
+
+// Handle registers the command
+func (r *Router) Handle(cmd *Command) error {
+	if cmd.Name == "" || strings.IndexFunc(cmd.Name, unicode.IsSpace) >= 0 {
+		return errors.Errorf("invalid command name %q", cmd.Name)
+	}
+	if cmd.Handler == nil {
+		return errors.Errorf("handler of command %q required", cmd.Name)
+	}
+	if _, found := r.commands[cmd.Name]; found || cmd.Name == "help" {
+		return errors.Errorf("command %q already registered", cmd.Name)
+	}
+
+	r.commands[cmd.Name] = cmd
+	return nil
+}
+
+// Callback implements callback.Handler, the command runs in background and
+// the result is sent via the webhook of chat, so it never replies synchronously,
+// the command keeps the values of ctx but not its cancellation and is limited
+// by the timeout of router
+func (r *Router) Callback(ctx context.Context, msg *callback.Message) (workrobot.Messager, error) {
+	if msg.MessageType != callback.MessageTypeText || msg.Text == nil {
+		return nil, nil
+	}
+
+	fields := Split(StripMentions(msg.Text.Content))
+	if len(fields) == 0 || !strings.HasPrefix(fields[0], r.prefix) {
+		return nil, nil
+	}
+	if msg.WebhookUrl == "" {
+		return nil, ErrMissingWebhook
+	}
+
+	// copied to avoid appending to the shared options in concurrent callbacks
+	options := append(append([]workrobot.ClientOption{}, r.clientOptions...), workrobot.WithWebhook(msg.WebhookUrl))
+	client, err := workrobot.NewClient("", options...)
+	if err != nil {
+		return nil, err
+	}
+
+	// the request is finished before the command, so it is not cancelled with it
+	ctx = context.WithoutCancel(ctx)
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+
+		reply, err := r.dispatch(ctx, msg, fields)
+		if err != nil {
+			reply, err = workrobot.NewText(fmt.Sprintf("%s failed: %s", fields[0], err))
+		}
+		if err == nil && reply != nil {
+			err = client.SendContext(ctx, reply)
+		}
+		if err != nil {
+			r.errorHandler(err)
+		}
+	}()
+	return nil, nil
+}
+
+// dispatch runs the command within the timeout of router
+func (r *Router) dispatch(ctx context.Context, msg *callback.Message, fields []string) (workrobot.Messager, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	return r.Dispatch(ctx, msg, fields)
+}
+
+// Dispatch runs the command by the fields of message
+func (r *Router) Dispatch(ctx context.Context, msg *callback.Message, fields []string) (workrobot.Messager, error) {
+	name := strings.TrimPrefix(fields[0], r.prefix)
+	if name == "help" {
+		return r.Help()
+	}
+
+	cmd, found := r.commands[name]
+	if !found {
+		return workrobot.NewText(fmt.Sprintf("unknown command %s, see %shelp", fields[0], r.prefix))
+	}
+
+	req := &Request{Message: msg, Command: cmd, Args: fields[1:]}
+	if !cmd.permitted(req.UserId()) {
+		text, err := workrobot.NewText(fmt.Sprintf("permission denied to run %s", fields[0]))
+		if err == nil && req.UserId() != "" {
+			text.MentionMember(req.UserId())
+		}
+		return text, err
+	}
+	if len(req.Args) < cmd.required() {
+		return workrobot.NewText("usage: " + cmd.Usage(r.prefix))
+	}
+
+	return cmd.Handler(ctx, req)
+}
+
+// Help returns the help text of all commands
+func (r *Router) Help() (workrobot.Messager, error) {
+	names := make([]string, 0, len(r.commands))
+	for name := range r.commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	lines := []string{"available commands:"}
+	for _, name := range names {
+		cmd := r.commands[name]
+		line := cmd.Usage(r.prefix)
+		if cmd.Description != "" {
+			line += " - " + cmd.Description
+		}
+		lines = append(lines, line)
+	}
+	lines = append(lines, r.prefix+"help - show this help")
+
+	return workrobot.NewText(strings.Join(lines, "\n"))
+}
+
+// Wait waits for all running commands finished
+func (r *Router) Wait() {
+	r.wg.Wait()
+}
+
+// StripMentions removes the leading @mentions of content
+func StripMentions(content string) string {
+	content = strings.TrimSpace(content)
+	for strings.HasPrefix(content, "@") {
+		i := strings.IndexFunc(content, unicode.IsSpace)
+		if i < 0 {
+			return ""
+		}
+		content = strings.TrimSpace(content[i:])
+	}
+	return content
+}
+
+// Split splits the command line by spaces, single or double quoted
+// arguments can contain spaces
+func Split(line string) (fields []string) {
+	var current strings.Builder
+	var quote rune
+	var inField bool
+	for _, c := range line {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else {
+				current.WriteRune(c)
+			}
+		case c == '"' || c == '\'':
+			quote, inField = c, true
+		case unicode.IsSpace(c):
+			if inField {
+				fields = append(fields, current.String())
+				current.Reset()
+				inField = false
+			}
+		default:
+			current.WriteRune(c)
+			inField = true
+		}
+	}
+	if inField {
+		fields = append(fields, current.String())
+	}
+	return
+}
+
+// Option represents additional router configuration
+type Option func(*Router)
+
+// WithPrefix sets the prefix of commands, defaults to /
+func WithPrefix(prefix string) Option {
+	return func(r *Router) {
+		r.prefix = prefix
+	}
+}
+
+// WithClientOptions sets the options of clients reply to chats
+func WithClientOptions(options ...workrobot.ClientOption) Option {
+	return func(r *Router) {
+		r.clientOptions = append(r.clientOptions, options...)
+	}
+}
+
+// WithErrorHandler sets the handler of errors occurred in background commands
+func WithErrorHandler(handler func(error)) Option {
+	return func(r *Router) {
+		r.errorHandler = handler
+	}
+}
+
+// WithTimeout sets the max duration of running a command, defaults to DefaultTimeout
+func WithTimeout(timeout time.Duration) Option {
+	return func(r *Router) {
+		if timeout > 0 {
+			r.timeout = timeout
+		}
+	}
+}
+
+// New create a command router, use Callback as the handler of callback server
+func New(options ...Option) *Router {
+	r := &Router{prefix: "/", commands: make(map[string]*Command), errorHandler: func(error) {}, timeout: DefaultTimeout}
+	for _, opt := range options {
+		opt(r)
+	}
+	return r
+}
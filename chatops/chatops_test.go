@@ -0,0 +1,198 @@
+package chatops
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wjiec/workrobot"
+	"github.com/wjiec/workrobot/callback"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplit(t *testing.T) {
+	assert.Equal(t, []string{"/deploy", "api", "prod"}, Split("/deploy  api prod "))
+	assert.Equal(t, []string{"/say", "hello world", "it's"}, Split(`/say "hello world" "it's"`))
+	assert.Equal(t, []string{"/say", ""}, Split(`/say ''`))
+	assert.Nil(t, Split("   "))
+}
+
+func TestStripMentions(t *testing.T) {
+	assert.Equal(t, "/deploy api", StripMentions("@RobotA @RobotB /deploy api"))
+	assert.Equal(t, "", StripMentions("@RobotA"))
+	assert.Equal(t, "hello", StripMentions(" hello"))
+}
+
+func TestRouter_Handle(t *testing.T) {
+	r := New()
+	handler := func(ctx context.Context, req *Request) (workrobot.Messager, error) { return nil, nil }
+
+	assert.NoError(t, r.Handle(&Command{Name: "deploy", Handler: handler}))
+	assert.Error(t, r.Handle(&Command{Name: "deploy", Handler: handler}))
+	assert.Error(t, r.Handle(&Command{Name: "help", Handler: handler}))
+	assert.Error(t, r.Handle(&Command{Name: "two words", Handler: handler}))
+	assert.Error(t, r.Handle(&Command{Name: "nohandler"}))
+}
+
+func TestRouter_Callback(t *testing.T) {
+	var mu sync.Mutex
+	var received []string
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bs, _ := ioutil.ReadAll(r.Body)
+
+		mu.Lock()
+		received = append(received, string(bs))
+		mu.Unlock()
+		_, _ = fmt.Fprint(w, `{"errcode":0,"errmsg":"ok"}`)
+	}))
+	defer gateway.Close()
+
+	r := New()
+	_ = r.Handle(&Command{
+		Name:        "deploy",
+		Args:        []string{"service", "env", "[tag]"},
+		Description: "deploy service to env",
+		Users:       []string{"zhangsan"},
+		Handler: func(ctx context.Context, req *Request) (workrobot.Messager, error) {
+			return workrobot.NewText(fmt.Sprintf("deploying %s to %s at %q", req.Arg("service"), req.Arg("env"), req.Arg("tag")))
+		},
+	})
+
+	send := func(user, content string) string {
+		mu.Lock()
+		received = nil
+		mu.Unlock()
+
+		reply, err := r.Callback(context.Background(), &callback.Message{
+			WebhookUrl:  gateway.URL,
+			From:        &callback.User{UserId: user},
+			MessageType: callback.MessageTypeText,
+			Text:        &callback.Text{Content: content},
+		})
+		assert.NoError(t, err)
+		assert.Nil(t, reply)
+		r.Wait()
+
+		mu.Lock()
+		defer mu.Unlock()
+		if len(received) == 0 {
+			return ""
+		}
+		return received[0]
+	}
+
+	assert.Contains(t, send("zhangsan", "@Robot /deploy api prod"), `deploying api to prod at \"\"`)
+	assert.Contains(t, send("zhangsan", "@Robot /deploy api prod v1.0"), `at \"v1.0\"`)
+	assert.Contains(t, send("zhangsan", "@Robot /deploy api"), `usage: /deploy \u003cservice\u003e \u003cenv\u003e [tag]`)
+	assert.Contains(t, send("lisi", "@Robot /deploy api prod"), "permission denied")
+	assert.Contains(t, send("lisi", "@Robot /rollback"), "unknown command /rollback")
+	assert.Contains(t, send("lisi", "@Robot /help"), "deploy service to env")
+	assert.Empty(t, send("lisi", "@Robot hello"))
+}
+
+func TestRouter_CallbackConcurrent(t *testing.T) {
+	var mu sync.Mutex
+	received := make(map[string]string)
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bs, _ := ioutil.ReadAll(r.Body)
+
+		mu.Lock()
+		received[r.URL.Path] = string(bs)
+		mu.Unlock()
+		_, _ = fmt.Fprint(w, `{"errcode":0,"errmsg":"ok"}`)
+	}))
+	defer gateway.Close()
+
+	// three options leave spare capacity in the shared options
+	hc := workrobot.WithHttpClient(http.DefaultClient)
+	r := New(WithClientOptions(hc), WithClientOptions(hc), WithClientOptions(hc))
+	_ = r.Handle(&Command{
+		Name: "echo",
+		Args: []string{"text"},
+		Handler: func(ctx context.Context, req *Request) (workrobot.Messager, error) {
+			return workrobot.NewText(req.Arg("text"))
+		},
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := r.Callback(context.Background(), &callback.Message{
+				WebhookUrl:  fmt.Sprintf("%s/chat-%d", gateway.URL, i),
+				From:        &callback.User{UserId: "zhangsan"},
+				MessageType: callback.MessageTypeText,
+				Text:        &callback.Text{Content: fmt.Sprintf("/echo chat-%d", i)},
+			})
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+	r.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if assert.Len(t, received, 20) {
+		for path, body := range received {
+			assert.Contains(t, body, `"content":"`+strings.TrimPrefix(path, "/")+`"`)
+		}
+	}
+}
+
+func TestRouter_CallbackContext(t *testing.T) {
+	var mu sync.Mutex
+	var received []string
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bs, _ := ioutil.ReadAll(r.Body)
+
+		mu.Lock()
+		received = append(received, string(bs))
+		mu.Unlock()
+		_, _ = fmt.Fprint(w, `{"errcode":0,"errmsg":"ok"}`)
+	}))
+	defer gateway.Close()
+
+	type key struct{}
+	var value interface{}
+	r := New(WithTimeout(10 * time.Millisecond))
+	_ = r.Handle(&Command{
+		Name: "wait",
+		Handler: func(ctx context.Context, req *Request) (workrobot.Messager, error) {
+			value = ctx.Value(key{})
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	})
+
+	// the command outlives the cancelled request until the timeout
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), key{}, "request"))
+	msg := &callback.Message{
+		WebhookUrl:  gateway.URL,
+		From:        &callback.User{UserId: "zhangsan"},
+		MessageType: callback.MessageTypeText,
+		Text:        &callback.Text{Content: "/wait"},
+	}
+	_, err := r.Callback(ctx, msg)
+	cancel()
+	assert.NoError(t, err)
+	r.Wait()
+
+	assert.Equal(t, "request", value)
+	mu.Lock()
+	if assert.Len(t, received, 1) {
+		assert.Contains(t, received[0], "/wait failed: context deadline exceeded")
+	}
+	mu.Unlock()
+
+	msg.WebhookUrl = ""
+	_, err = r.Callback(context.Background(), msg)
+	assert.Equal(t, ErrMissingWebhook, err)
+}
@@ -8,12 +8,15 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strings"
 	"sync"
+	"time"
 
 	uploader "github.com/wjiec/workrobot/media"
 
 	"github.com/pkg/errors"
 	"go.uber.org/multierr"
+	"golang.org/x/time/rate"
 )
 
 const (
@@ -23,19 +26,36 @@ const (
 	DefaultUploadGateway = "https://qyapi.weixin.qq.com/cgi-bin/webhook/upload_media"
 )
 
+// ErrMissingKey represents the upload gateway has no robot key
+var ErrMissingKey = errors.New("robot key required to upload")
+
 // Client represents a robot client
 type Client struct {
 	hc *http.Client
 
-	key     string
-	webhook string
+	key           string
+	webhook       string
+	uploadGateway string
+
+	interceptors       []Interceptor
+	uploadInterceptors []uploader.Interceptor
+
+	dryRun  *dryRun
+	retry   *retryPolicy
+	limiter *rate.Limiter
 }
 
 // Send send messages to the group in order, when error occurs
 // will be returns immediately and skip the rest of the messages
 func (c *Client) Send(messages ...Messager) error {
+	return c.SendContext(context.Background(), messages...)
+}
+
+// SendContext send messages to the group in order with the context,
+// and returns immediately when error occurs like Send
+func (c *Client) SendContext(ctx context.Context, messages ...Messager) error {
 	for _, msg := range messages {
-		if err := c.doSend(context.Background(), msg); err != nil {
+		if err := c.doSend(ctx, msg); err != nil {
 			return err
 		}
 	}
@@ -49,11 +69,18 @@ func (c *Client) Send(messages ...Messager) error {
 //
 // concurrency limit: 20/min, 2/sec
 // see https://work.weixin.qq.com/api/doc/90000/90136/91770#消息发送频率限制
-func (c *Client) SendConcurrency(fastFail bool, messages ...Messager) (err error) {
+func (c *Client) SendConcurrency(fastFail bool, messages ...Messager) error {
+	return c.SendConcurrencyContext(context.Background(), fastFail, messages...)
+}
+
+// SendConcurrencyContext send message to the group concurrency with the
+// context, and error will returns like SendConcurrency
+func (c *Client) SendConcurrencyContext(ctx context.Context, fastFail bool, messages ...Messager) (err error) {
 	var wg sync.WaitGroup
 	var failed sync.Once
 	errs := make(chan error, len(messages))
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
 	for _, msg := range messages {
 		wg.Add(1)
@@ -84,20 +111,52 @@ func (c *Client) SendConcurrency(fastFail bool, messages ...Messager) (err error
 	}
 }
 
-// wxSendReceipt represents an receipt from workWx
-type wxSendReceipt struct {
+// Receipt represents a send receipt from workWx, and returns as
+// error when the gateway rejects the message
+type Receipt struct {
 	Code    int    `json:"errcode"`
 	Message string `json:"errmsg"`
 }
 
 // Error build error message and returns when error occurs
-func (r *wxSendReceipt) Error() string {
+func (r *Receipt) Error() string {
 	return fmt.Sprintf("%d: %s", r.Code, r.Message)
 }
 
-// doSend send single message to the group
+// doSend send single message to the group through the interceptors
 func (c *Client) doSend(ctx context.Context, msg Messager) error {
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.webhook, bytes.NewReader(msg.Message()))
+	return chain(c.interceptors, c.handler(true))(ctx, &Call{Message: msg, Webhook: c.webhook})
+}
+
+// handler returns the handler transmits the call, which is throttled and
+// retried, the dry-run messages are rendered without throttling and retrying
+func (c *Client) handler(throttled bool) Handler {
+	if c.dryRun != nil && !c.dryRun.allowed(c) {
+		return c.dryRun.transmit
+	}
+
+	handler := c.transmit
+	if throttled && c.limiter != nil {
+		handler = c.throttle(handler)
+	}
+	if c.retry != nil {
+		handler = c.retry.wrap(handler)
+	}
+	return handler
+}
+
+// transmit send the message of call to the gateway and record the receipt
+func (c *Client) transmit(ctx context.Context, call *Call) error {
+	call.Attempt++
+	call.Receipt = nil
+
+	start := time.Now()
+	if call.Dispatched.IsZero() {
+		call.Dispatched = start
+	}
+	defer func() { call.Latency = time.Since(start) }()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.webhook, bytes.NewReader(call.Payload()))
 	if err != nil {
 		return errors.Wrap(err, "bad request")
 	}
@@ -113,27 +172,55 @@ func (c *Client) doSend(ctx context.Context, msg Messager) error {
 		return errors.Wrap(err, "unreadable http response")
 	}
 
-	var receipt wxSendReceipt
+	var receipt Receipt
 	if err := json.Unmarshal(bs, &receipt); err != nil {
 		return errors.Wrap(err, "wrong http response data")
 	}
 
+	call.Receipt = &receipt
 	if receipt.Code != 0 {
 		return &receipt
 	}
 	return nil
 }
 
-// Uploader returns the uploader for current robot
+// Uploader returns the uploader for current robot, the files are uploaded to
+// the gateway of WithUploadGateway, or the one derived from the webhook, with
+// the key of robot or webhook, uploads fail with ErrMissingKey without any key
 func (c *Client) Uploader() *uploader.Uploader {
-	endpoint, _ := url.Parse(DefaultUploadGateway)
+	gateway := c.uploadGateway
+	if gateway == "" {
+		gateway = UploadGateway(c.webhook)
+	}
+
+	endpoint, _ := url.Parse(gateway)
+
+	key := c.key
+	if key == "" {
+		key = webhookKey(c.webhook)
+	}
 
 	q := endpoint.Query()
-	q.Add("key", c.key)
-	q.Add("type", "file")
+	if q.Get("key") == "" && key != "" {
+		q.Set("key", key)
+	}
+	q.Set("type", "file")
 	endpoint.RawQuery = q.Encode()
 
-	return uploader.New(c.hc, endpoint.String())
+	interceptors := append([]uploader.Interceptor{}, c.uploadInterceptors...)
+	if c.dryRun != nil && !c.dryRun.allowed(c) {
+		interceptors = append(interceptors, c.dryRun.upload)
+	}
+	if q.Get("key") == "" {
+		interceptors = append(interceptors, rejectUpload)
+	}
+	return uploader.New(c.hc, endpoint.String(), interceptors...)
+}
+
+// rejectUpload rejects the upload without key instead of sending the file
+// to the gateway
+func rejectUpload(context.Context, *uploader.Upload, uploader.Handler) error {
+	return ErrMissingKey
 }
 
 // ClientOption represents additional robot configuration
@@ -160,6 +247,29 @@ func WithWebhook(webhook string) ClientOption {
 	}
 }
 
+// WithUploadGateway override the upload address of robot, the key of robot
+// is added when the address has no key
+func WithUploadGateway(gateway string) ClientOption {
+	return func(client *Client) error {
+		api, err := url.Parse(gateway)
+		if err != nil {
+			return err
+		}
+
+		client.uploadGateway = api.String()
+		return nil
+	}
+}
+
+// WithUploadInterceptor append interceptors to the uploader of robot, the first
+// registered interceptor is the outermost one and runs first
+func WithUploadInterceptor(interceptors ...uploader.Interceptor) ClientOption {
+	return func(client *Client) error {
+		client.uploadInterceptors = append(client.uploadInterceptors, interceptors...)
+		return nil
+	}
+}
+
 // NewClient create a instance of robot
 func NewClient(key string, options ...ClientOption) (*Client, error) {
 	c := &Client{hc: http.DefaultClient, webhook: Webhook(key), key: key}
@@ -181,3 +291,33 @@ func Webhook(key string) string {
 	api.RawQuery = q.Encode()
 	return api.String()
 }
+
+// webhookKey returns the key parameter of webhook
+func webhookKey(webhook string) string {
+	if api, err := url.Parse(webhook); err == nil {
+		return api.Query().Get("key")
+	}
+	return ""
+}
+
+// UploadGateway build upload address from the robot webhook, it shares the
+// host and key of the webhook, and DefaultUploadGateway with the key of
+// webhook is returned when the webhook is not a send address
+func UploadGateway(webhook string) string {
+	api, err := url.Parse(webhook)
+	if err != nil {
+		return DefaultUploadGateway
+	}
+
+	if !strings.HasSuffix(api.Path, "/send") {
+		gateway, _ := url.Parse(DefaultUploadGateway)
+		if key := api.Query().Get("key"); key != "" {
+			gateway.RawQuery = url.Values{"key": {key}}.Encode()
+		}
+		return gateway.String()
+	}
+
+	api.Path = strings.TrimSuffix(api.Path, "/send") + "/upload_media"
+	api.RawPath = ""
+	return api.String()
+}
@@ -1,8 +1,12 @@
 package workrobot
 
 import (
+	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestWithHttpClient(t *testing.T) {
@@ -25,3 +29,42 @@ func TestWithWebhook(t *testing.T) {
 		t.Errorf("unexpected webhook")
 	}
 }
+
+func TestClient_Uploader(t *testing.T) {
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.URL.Path+"?"+r.URL.RawQuery)
+		_, _ = fmt.Fprint(w, `{"errcode":0,"errmsg":"ok","type":"file","media_id":"media-1","created_at":"1380000000"}`)
+	}))
+	defer server.Close()
+
+	c, _ := NewClient("", WithWebhook(server.URL+"/cgi-bin/webhook/send?key=from-webhook"))
+	_, err := c.Uploader().UploadFromBytes("a.txt", []byte("a"))
+	assert.NoError(t, err)
+
+	c, _ = NewClient("test-key", WithUploadGateway(server.URL+"/upload"))
+	_, err = c.Uploader().UploadFromBytes("b.txt", []byte("b"))
+	assert.NoError(t, err)
+
+	c, _ = NewClient("", WithWebhook(server.URL+"/robot?key=from-query"), WithUploadGateway(server.URL+"/upload"))
+	_, err = c.Uploader().UploadFromBytes("c.txt", []byte("c"))
+	assert.NoError(t, err)
+
+	c, _ = NewClient("", WithWebhook(server.URL+"/robot"), WithUploadGateway(server.URL+"/upload"))
+	_, err = c.Uploader().UploadFromBytes("d.txt", []byte("d"))
+	assert.ErrorIs(t, err, ErrMissingKey)
+
+	assert.Equal(t, []string{
+		"/cgi-bin/webhook/upload_media?key=from-webhook&type=file",
+		"/upload?key=test-key&type=file",
+		"/upload?key=from-query&type=file",
+	}, received)
+}
+
+func TestUploadGateway(t *testing.T) {
+	assert.Equal(t, "https://work.example.com/webhook/upload_media?key=test-case",
+		UploadGateway("https://work.example.com/webhook/send?key=test-case"))
+	assert.Equal(t, DefaultUploadGateway, UploadGateway("http://127.0.0.1:8080"))
+	assert.Equal(t, DefaultUploadGateway+"?key=test-case", UploadGateway("http://127.0.0.1:8080/robot?key=test-case"))
+	assert.Equal(t, DefaultUploadGateway, UploadGateway("://invalid"))
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/wjiec/workrobot/job"
+)
+
+func init() {
+	commands["exec"] = &command{usage: "run a command and report its result", run: runExec}
+}
+
+// notify modes of exec
+var notifyModes = map[string]job.Notify{
+	"always":  job.NotifyAlways,
+	"failure": job.NotifyFailure,
+	"change":  job.NotifyChange,
+}
+
+// runExec runs the command after flags and exits with its exit code
+func runExec(env *environment, args []string) error {
+	fs, rf := newFlagSet(env, "exec")
+	title := fs.String("title", "", "title of result, defaults to the command name")
+	notify := fs.String("notify", "always", "when to send the result: always, failure or change")
+	state := fs.String("state", "", "state file remembers the result of -notify change")
+	lines := fs.Int("tail", 20, "max lines of output in message")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	mode, found := notifyModes[*notify]
+	if !found {
+		return usagef("unknown notify mode %q", *notify)
+	}
+	if mode == job.NotifyChange && *state == "" {
+		return usagef("-state required by -notify change")
+	}
+	if fs.NArg() == 0 {
+		return usagef("command required")
+	}
+
+	client, err := rf.client()
+	if err != nil {
+		return err
+	}
+
+	runner := job.New(client, job.WithTitle(*title), job.WithNotify(mode, *state), job.WithTailLines(*lines),
+		job.WithInput(env.stdin), job.WithOutput(env.stdout, env.stderr))
+	result, err := runner.Run(context.Background(), fs.Arg(0), fs.Args()[1:]...)
+	if result.Err != nil {
+		_, _ = fmt.Fprintf(env.stderr, "workrobot exec: %s\n", result.Err)
+	}
+	if err != nil {
+		_, _ = fmt.Fprintf(env.stderr, "workrobot exec: cannot send result: %s\n", err)
+	}
+
+	if !result.Success() {
+		return exitStatus(result.ExitCode)
+	}
+	if err != nil {
+		return exitStatus(exitCode(err))
+	}
+	return nil
+}
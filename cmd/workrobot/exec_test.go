@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunExec(t *testing.T) {
+	var received []string
+	server := newTestServer(0, &received)
+	defer server.Close()
+
+	env, _ := newTestEnv("", nil)
+	assert.Equal(t, 3, run(env, []string{"exec", "-webhook", server.URL, "-title", "backup", "--", "sh", "-c", "echo failed; exit 3"}))
+	if assert.Len(t, received, 1) {
+		assert.Contains(t, received[0], "**backup**")
+		assert.Contains(t, received[0], "failed")
+	}
+
+	env, _ = newTestEnv("", nil)
+	assert.Equal(t, exitOK, run(env, []string{"exec", "-webhook", server.URL, "-notify", "failure", "--", "true"}))
+	assert.Len(t, received, 1)
+
+	env, _ = newTestEnv("", nil)
+	assert.Equal(t, exitUsage, run(env, []string{"exec", "-webhook", server.URL, "-notify", "change", "--", "true"}))
+
+	var rejected []string
+	gateway := newTestServer(93000, &rejected)
+	defer gateway.Close()
+
+	env, stderr := newTestEnv("", nil)
+	assert.Equal(t, exitInvalidKey, run(env, []string{"exec", "-webhook", gateway.URL, "--", "true"}))
+	assert.Contains(t, stderr.String(), "cannot send result")
+}
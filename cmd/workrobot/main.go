@@ -0,0 +1,171 @@
+// Command workrobot sends work-wx robot messages from the shell.
+//
+// Usage:
+//
+//	workrobot <command> [flags] [arguments]
+//
+// The robot is selected by -key, -webhook or -robot with -config, and
+// falls back to environment variables WORKROBOT_KEY, WORKROBOT_WEBHOOK,
+// WORKROBOT_ROBOT and WORKROBOT_CONFIG.
+//
+// Exit codes:
+//
+//	0  message sent
+//	1  local or network error
+//	2  usage error
+//	3  message rejected by the gateway
+//	4  gateway rate limit exceeded (errcode 45009)
+//	5  invalid robot key or webhook (errcode 93000)
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/wjiec/workrobot"
+	"github.com/wjiec/workrobot/media"
+)
+
+const (
+	exitOK          = 0
+	exitError       = 1
+	exitUsage       = 2
+	exitGateway     = 3
+	exitRateLimited = 4
+	exitInvalidKey  = 5
+)
+
+// gateway errcodes with dedicated exit codes
+const (
+	errcodeFreqLimit  = 45009
+	errcodeInvalidKey = 93000
+)
+
+// environment represents the io and environment variables of command
+type environment struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+	getenv func(string) string
+}
+
+// command represents a sub command of workrobot
+type command struct {
+	usage string
+	run   func(env *environment, args []string) error
+}
+
+// commands are all sub commands by name
+var commands = map[string]*command{}
+
+// usageError represents the command line arguments are invalid
+type usageError struct {
+	message string
+}
+
+// Error returns the usage error message
+func (e *usageError) Error() string {
+	return e.message
+}
+
+// usagef create a usage error with formatted message
+func usagef(format string, args ...interface{}) error {
+	return &usageError{message: fmt.Sprintf(format, args...)}
+}
+
+// exitStatus represents the command exits with the status silently
+type exitStatus int
+
+// Error returns the exit status message
+func (s exitStatus) Error() string {
+	return fmt.Sprintf("exit status %d", int(s))
+}
+
+// run execute the command line and returns the exit code
+func run(env *environment, args []string) int {
+	if len(args) == 0 || commands[args[0]] == nil {
+		printUsage(env.stderr)
+		return exitUsage
+	}
+
+	if err := commands[args[0]].run(env, args[1:]); err != nil {
+		if err == flag.ErrHelp {
+			return exitUsage
+		}
+
+		var status exitStatus
+		if errors.As(err, &status) {
+			return int(status)
+		}
+
+		_, _ = fmt.Fprintf(env.stderr, "workrobot %s: %s\n", args[0], err)
+		return exitCode(err)
+	}
+	return exitOK
+}
+
+// exitCode returns the exit code of error
+func exitCode(err error) int {
+	var usage *usageError
+	if errors.As(err, &usage) {
+		return exitUsage
+	}
+
+	var code int
+	var receipt *workrobot.Receipt
+	var uploadReceipt *media.Receipt
+	switch {
+	case errors.As(err, &receipt):
+		code = receipt.Code
+	case errors.As(err, &uploadReceipt):
+		code = uploadReceipt.Code
+	default:
+		return exitError
+	}
+
+	switch code {
+	case errcodeFreqLimit:
+		return exitRateLimited
+	case errcodeInvalidKey:
+		return exitInvalidKey
+	}
+	return exitGateway
+}
+
+// printUsage prints all commands
+func printUsage(w io.Writer) {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	_, _ = fmt.Fprintln(w, "usage: workrobot <command> [flags] [arguments]")
+	_, _ = fmt.Fprintln(w, "\ncommands:")
+	for _, name := range names {
+		_, _ = fmt.Fprintf(w, "  %-10s %s\n", name, commands[name].usage)
+	}
+}
+
+// stringsFlag represents a flag can be specified multiple times
+type stringsFlag []string
+
+// String returns the values joined by comma
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+// Set append a value
+func (s *stringsFlag) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+func main() {
+	os.Exit(run(&environment{stdin: os.Stdin, stdout: os.Stdout, stderr: os.Stderr, getenv: os.Getenv}, os.Args[1:]))
+}
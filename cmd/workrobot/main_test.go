@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestEnv(stdin string, vars map[string]string) (*environment, *bytes.Buffer) {
+	var stderr bytes.Buffer
+	return &environment{
+		stdin:  strings.NewReader(stdin),
+		stdout: ioutil.Discard,
+		stderr: &stderr,
+		getenv: func(k string) string { return vars[k] },
+	}, &stderr
+}
+
+func newTestServer(errcode int, received *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bs, _ := ioutil.ReadAll(r.Body)
+		*received = append(*received, string(bs))
+		_, _ = fmt.Fprintf(w, `{"errcode":%d,"errmsg":"test"}`, errcode)
+	}))
+}
+
+func TestRun_Text(t *testing.T) {
+	var received []string
+	server := newTestServer(0, &received)
+	defer server.Close()
+
+	env, _ := newTestEnv("", nil)
+	code := run(env, []string{"text", "-webhook", server.URL, "-mention", "jayson", "-all", "hello", "world"})
+	assert.Equal(t, exitOK, code)
+	if assert.Len(t, received, 1) {
+		assert.Contains(t, received[0], `"content":"hello world"`)
+		assert.Contains(t, received[0], `"jayson","@all"`)
+	}
+
+	env, _ = newTestEnv("from stdin\n", map[string]string{"WORKROBOT_WEBHOOK": server.URL})
+	assert.Equal(t, exitOK, run(env, []string{"text"}))
+	assert.Contains(t, received[1], `"content":"from stdin"`)
+}
+
+func TestRun_Markdown(t *testing.T) {
+	var received []string
+	server := newTestServer(0, &received)
+	defer server.Close()
+
+	env, _ := newTestEnv("# title\n> quote\n", nil)
+	assert.Equal(t, exitOK, run(env, []string{"markdown", "-webhook", server.URL}))
+	assert.Contains(t, received[0], `"content":"# title\n\u003e quote"`)
+}
+
+func TestRun_Image(t *testing.T) {
+	var received []string
+	server := newTestServer(0, &received)
+	defer server.Close()
+
+	var buf bytes.Buffer
+	_ = png.Encode(&buf, image.NewGray(image.Rect(0, 0, 8, 8)))
+	filename := filepath.Join(t.TempDir(), "test.png")
+	_ = ioutil.WriteFile(filename, buf.Bytes(), 0644)
+
+	env, stderr := newTestEnv("", nil)
+	assert.Equal(t, exitOK, run(env, []string{"image", "-webhook", server.URL, "-compress", filename}))
+	assert.Empty(t, stderr.String())
+	if assert.Len(t, received, 1) {
+		assert.Contains(t, received[0], `"msgtype":"image"`)
+	}
+}
+
+func TestRun_News(t *testing.T) {
+	var received []string
+	server := newTestServer(0, &received)
+	defer server.Close()
+
+	env, _ := newTestEnv(`[{"title":"release","url":"https://example.com"}]`, nil)
+	assert.Equal(t, exitOK, run(env, []string{"news", "-webhook", server.URL, "-json", "-"}))
+	assert.Contains(t, received[0], `"title":"release"`)
+
+	env, _ = newTestEnv("", nil)
+	assert.Equal(t, exitUsage, run(env, []string{"news", "-webhook", server.URL, "-title", "no link"}))
+}
+
+func TestRun_ExitCode(t *testing.T) {
+	var received []string
+	limited := newTestServer(45009, &received)
+	defer limited.Close()
+	rejected := newTestServer(40058, &received)
+	defer rejected.Close()
+
+	env, stderr := newTestEnv("", nil)
+	assert.Equal(t, exitRateLimited, run(env, []string{"text", "-webhook", limited.URL, "hello"}))
+	assert.Contains(t, stderr.String(), "45009")
+	assert.Equal(t, exitGateway, run(env, []string{"text", "-webhook", rejected.URL, "hello"}))
+
+	filename := filepath.Join(t.TempDir(), "report.txt")
+	_ = ioutil.WriteFile(filename, []byte("report"), 0644)
+	assert.Equal(t, exitRateLimited, run(env, []string{"file", "-webhook", limited.URL + "/send?key=test", filename}))
+	assert.Equal(t, exitGateway, run(env, []string{"file", "-webhook", rejected.URL + "/send?key=test", filename}))
+
+	assert.Equal(t, exitUsage, run(env, []string{"text", "hello"}))
+	assert.Equal(t, exitUsage, run(env, []string{"unknown"}))
+	assert.Equal(t, exitError, run(env, []string{"image", "-key", "test", "/not/exists.png"}))
+}
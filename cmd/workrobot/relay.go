@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/wjiec/workrobot/config"
+	"github.com/wjiec/workrobot/internal/strutil"
+	"github.com/wjiec/workrobot/relay"
+
+	"github.com/pkg/errors"
+)
+
+func init() {
+	commands["relay"] = &command{usage: "serve http api forwards messages to robots", run: runRelay}
+}
+
+// runRelay serves the relay server for robots in config
+func runRelay(env *environment, args []string) error {
+	fs, rf := newFlagSet(env, "relay")
+	listen := fs.String("listen", "127.0.0.1:8080", "listen address")
+	tokens := fs.String("tokens", "", "tokens file, each line is a token followed by allowed robots or *")
+	queryToken := fs.Bool("query-token", false, "accept token from the token parameter of url")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	filename := strutil.FirstOf(rf.config, env.getenv("WORKROBOT_CONFIG"))
+	if filename == "" {
+		return usagef("-config required")
+	}
+	if *tokens == "" {
+		return usagef("-tokens required")
+	}
+
+	cfg, err := config.Load(filename)
+	if err != nil {
+		return err
+	}
+	registry, err := cfg.Registry()
+	if err != nil {
+		return err
+	}
+
+	options, err := loadTokens(*tokens)
+	if err != nil {
+		return err
+	}
+	if *queryToken {
+		options = append(options, relay.WithQueryToken())
+	}
+
+	server := &http.Server{Addr: *listen, Handler: relay.New(registry.Clients(), options...)}
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		<-signals
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = server.Shutdown(ctx)
+	}()
+
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// loadTokens read tokens from file, blank lines and lines start with # are ignored
+func loadTokens(filename string) ([]relay.Option, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot open tokens")
+	}
+	defer func() { _ = f.Close() }()
+
+	var options []relay.Option
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) == 1 {
+			return nil, errors.Errorf("no robots allowed for token %s...", fields[0][:1])
+		}
+		options = append(options, relay.WithToken(fields[0], fields[1:]...))
+	}
+	return options, errors.Wrap(scanner.Err(), "tokens unreadable")
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"github.com/wjiec/workrobot/testreport"
+
+	"github.com/pkg/errors"
+)
+
+func init() {
+	commands["report"] = &command{usage: "send summary of go test -json or junit reports", run: runReport}
+}
+
+// runReport parses the test reports in files or stdin and sends the summary
+func runReport(env *environment, args []string) error {
+	fs, rf := newFlagSet(env, "report")
+	title := fs.String("title", "", "title of summary")
+	slowest := fs.Int("slowest", 5, "count of slowest tests listed")
+	upload := fs.Bool("upload", true, "upload the failure log as a file")
+	exitCode := fs.Bool("exit-code", false, "exit with 1 when there are failed tests")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	client, err := rf.client()
+	if err != nil {
+		return err
+	}
+
+	summary := &testreport.Summary{}
+	if fs.NArg() == 0 {
+		if summary, err = testreport.Parse(env.stdin); err != nil {
+			return err
+		}
+	}
+	for _, filename := range fs.Args() {
+		s, err := parseReport(filename)
+		if err != nil {
+			return err
+		}
+
+		summary.Results = append(summary.Results, s.Results...)
+		summary.Elapsed += s.Elapsed
+	}
+
+	reporter := testreport.New(client, testreport.WithTitle(*title),
+		testreport.WithSlowest(*slowest), testreport.WithUploadLog(*upload))
+	if err := reporter.Send(context.Background(), summary); err != nil {
+		return err
+	}
+
+	if failed := len(summary.Failed()); *exitCode && failed != 0 {
+		return errors.Errorf("%d tests failed", failed)
+	}
+	return nil
+}
+
+// parseReport parses the test report file
+func parseReport(filename string) (*testreport.Summary, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot open report")
+	}
+	defer func() { _ = f.Close() }()
+
+	return testreport.Parse(f)
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunReport(t *testing.T) {
+	var received []string
+	server := newTestServer(0, &received)
+	defer server.Close()
+
+	env, _ := newTestEnv("", nil)
+	code := run(env, []string{"report", "-webhook", server.URL, "-upload=false", "-title", "ci",
+		"../../testreport/testdata/gotest.json", "../../testreport/testdata/junit.xml"})
+	assert.Equal(t, exitOK, code)
+	if assert.Len(t, received, 1) {
+		assert.Contains(t, received[0], "2 passed, 3 failed, 2 skipped")
+		assert.Contains(t, received[0], "com.example.CalculatorTest.testSub")
+	}
+
+	env, stderr := newTestEnv(`{"Action":"fail","Package":"example.com/app","Test":"TestSub"}`, nil)
+	assert.Equal(t, exitError, run(env, []string{"report", "-webhook", server.URL, "-upload=false", "-exit-code"}))
+	assert.Contains(t, stderr.String(), "1 tests failed")
+}
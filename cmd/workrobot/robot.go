@@ -0,0 +1,96 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/wjiec/workrobot"
+	"github.com/wjiec/workrobot/config"
+	"github.com/wjiec/workrobot/internal/strutil"
+
+	"github.com/pkg/errors"
+)
+
+// robotFlags represents the flags select the robot
+type robotFlags struct {
+	env *environment
+
+	key     string
+	webhook string
+	robot   string
+	config  string
+}
+
+// client returns the client of selected robot, flags take precedence
+// over the environment variables
+func (rf *robotFlags) client(options ...workrobot.ClientOption) (*workrobot.Client, error) {
+	key := strutil.FirstOf(rf.key, rf.env.getenv("WORKROBOT_KEY"))
+	webhook := strutil.FirstOf(rf.webhook, rf.env.getenv("WORKROBOT_WEBHOOK"))
+	robot := strutil.FirstOf(rf.robot, rf.env.getenv("WORKROBOT_ROBOT"))
+	filename := strutil.FirstOf(rf.config, rf.env.getenv("WORKROBOT_CONFIG"))
+
+	switch {
+	case rf.key != "" || rf.webhook != "":
+		return newClient(rf.key, rf.webhook, options...)
+	case robot != "":
+		if filename == "" {
+			return nil, usagef("-config required to select robot %q", robot)
+		}
+
+		cfg, err := config.Load(filename)
+		if err != nil {
+			return nil, err
+		}
+		registry, err := cfg.Registry(options...)
+		if err != nil {
+			return nil, err
+		}
+		return registry.Get(robot)
+	case key != "" || webhook != "":
+		return newClient(key, webhook, options...)
+	}
+	return nil, usagef("robot required, specify by -key, -webhook or -robot")
+}
+
+// newClient create client from key or webhook
+func newClient(key, webhook string, options ...workrobot.ClientOption) (*workrobot.Client, error) {
+	if webhook != "" {
+		options = append([]workrobot.ClientOption{workrobot.WithWebhook(webhook)}, options...)
+	}
+	return workrobot.NewClient(key, options...)
+}
+
+// newFlagSet create the flag set of command with robot flags
+func newFlagSet(env *environment, name string) (*flag.FlagSet, *robotFlags) {
+	fs := flag.NewFlagSet("workrobot "+name, flag.ContinueOnError)
+	fs.SetOutput(env.stderr)
+
+	rf := &robotFlags{env: env}
+	fs.StringVar(&rf.key, "key", "", "robot key (env WORKROBOT_KEY)")
+	fs.StringVar(&rf.webhook, "webhook", "", "robot webhook address (env WORKROBOT_WEBHOOK)")
+	fs.StringVar(&rf.robot, "robot", "", "robot name in config file (env WORKROBOT_ROBOT)")
+	fs.StringVar(&rf.config, "config", "", "robots config file (env WORKROBOT_CONFIG)")
+
+	return fs, rf
+}
+
+// readInput returns the content of file, or stdin when filename is empty or -
+func readInput(env *environment, filename string) (string, error) {
+	var reader io.Reader = env.stdin
+	if filename != "" && filename != "-" {
+		f, err := os.Open(filename)
+		if err != nil {
+			return "", errors.Wrap(err, "cannot open file")
+		}
+		defer func() { _ = f.Close() }()
+		reader = f
+	}
+
+	bs, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return "", errors.Wrap(err, "input unreadable")
+	}
+	return string(bs), nil
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/wjiec/workrobot"
+
+	"github.com/pkg/errors"
+)
+
+func init() {
+	commands["text"] = &command{usage: "send text message from arguments or stdin", run: runText}
+	commands["markdown"] = &command{usage: "send markdown message from file or stdin", run: runMarkdown}
+	commands["image"] = &command{usage: "send jpg or png image file", run: runImage}
+	commands["file"] = &command{usage: "upload and send file", run: runFile}
+	commands["news"] = &command{usage: "send news card from flags or json", run: runNews}
+}
+
+// runText sends text message with mentions
+func runText(env *environment, args []string) error {
+	fs, rf := newFlagSet(env, "text")
+	var members, mobiles stringsFlag
+	fs.Var(&members, "mention", "mention member by userid, repeatable")
+	fs.Var(&mobiles, "mention-mobile", "mention member by mobile, repeatable")
+	all := fs.Bool("all", false, "mention all members")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	content := strings.Join(fs.Args(), " ")
+	if fs.NArg() == 0 {
+		input, err := readInput(env, "")
+		if err != nil {
+			return err
+		}
+		content = strings.TrimRight(input, "\n")
+	}
+
+	text, err := workrobot.NewText(content)
+	if err != nil {
+		return err
+	}
+	for _, member := range members {
+		text.MentionMember(member)
+	}
+	for _, mobile := range mobiles {
+		text.MentionMobile(mobile)
+	}
+	text.MentionAll(*all)
+
+	return send(rf, text)
+}
+
+// runMarkdown sends markdown message from file or stdin
+func runMarkdown(env *environment, args []string) error {
+	fs, rf := newFlagSet(env, "markdown")
+	file := fs.String("file", "-", "markdown file, - for stdin")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	content, err := readInput(env, *file)
+	if err != nil {
+		return err
+	}
+
+	var markdown workrobot.Markdown
+	if err := markdown.RawContent(strings.TrimRight(content, "\n")); err != nil {
+		return err
+	}
+	return send(rf, &markdown)
+}
+
+// runImage sends image from file
+func runImage(env *environment, args []string) error {
+	fs, rf := newFlagSet(env, "image")
+	compress := fs.Bool("compress", false, "downscale and recompress image exceeding the size limit")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usagef("exactly one image file required")
+	}
+
+	var options []workrobot.ImageOption
+	if *compress {
+		options = append(options, workrobot.WithImageCompression())
+	}
+
+	image, err := workrobot.NewImageFromFile(fs.Arg(0), options...)
+	if err != nil {
+		return err
+	}
+	if c := image.Compression(); c != nil {
+		_, _ = fmt.Fprintf(env.stderr, "workrobot image: compressed from %d to %d bytes, %dx%d in quality %d\n",
+			c.OriginalSize, c.Size, c.Width, c.Height, c.Quality)
+	}
+	return send(rf, image)
+}
+
+// runFile uploads the file and sends it
+func runFile(env *environment, args []string) error {
+	fs, rf := newFlagSet(env, "file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usagef("exactly one file required")
+	}
+
+	client, err := rf.client()
+	if err != nil {
+		return err
+	}
+
+	media, err := client.Uploader().UploadFromFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	return client.Send(workrobot.NewMedia(media.Id))
+}
+
+// runNews sends news card from flags or json
+func runNews(env *environment, args []string) error {
+	fs, rf := newFlagSet(env, "news")
+	var article workrobot.Article
+	fs.StringVar(&article.Title, "title", "", "article title")
+	fs.StringVar(&article.Link, "url", "", "article link")
+	fs.StringVar(&article.Description, "description", "", "article description")
+	fs.StringVar(&article.ImageUrl, "picurl", "", "article picture url")
+	file := fs.String("json", "", "json file of an article or article list, - for stdin")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	articles := []*workrobot.Article{&article}
+	if *file != "" {
+		var err error
+		if articles, err = readArticles(env, *file); err != nil {
+			return err
+		}
+	}
+
+	card, err := workrobot.NewCard(articles...)
+	if err != nil {
+		return usagef("%s", err)
+	}
+	return send(rf, card)
+}
+
+// readArticles read an article or article list from json file
+func readArticles(env *environment, filename string) ([]*workrobot.Article, error) {
+	content, err := readInput(env, filename)
+	if err != nil {
+		return nil, err
+	}
+
+	data := bytes.TrimSpace([]byte(content))
+	if bytes.HasPrefix(data, []byte("[")) {
+		var articles []*workrobot.Article
+		if err := json.Unmarshal(data, &articles); err != nil {
+			return nil, errors.Wrap(err, "invalid articles")
+		}
+		return articles, nil
+	}
+
+	var article workrobot.Article
+	if err := json.Unmarshal(data, &article); err != nil {
+		return nil, errors.Wrap(err, "invalid article")
+	}
+	return []*workrobot.Article{&article}, nil
+}
+
+// send sends the message to selected robot
+func send(rf *robotFlags, msg workrobot.Messager) error {
+	client, err := rf.client()
+	if err != nil {
+		return err
+	}
+	return client.Send(msg)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"time"
+
+	"github.com/wjiec/workrobot"
+
+	"github.com/pkg/errors"
+)
+
+func init() {
+	commands["tail"] = &command{usage: "stream stdin lines into batched messages", run: runTail}
+}
+
+// output formats of tail
+var tailFormats = map[string]workrobot.WriterFormat{
+	"plain": workrobot.WriterText,
+	"quote": workrobot.WriterQuote,
+	"code":  workrobot.WriterCode,
+}
+
+// runTail reads stdin continuously and sends lines in batches
+func runTail(env *environment, args []string) error {
+	fs, rf := newFlagSet(env, "tail")
+	filter := fs.String("filter", "", "only send lines matched the regexp")
+	format := fs.String("format", "plain", "output format: plain, quote or code")
+	interval := fs.Duration("interval", 5*time.Second, "flush interval of pending lines")
+	rate := fs.Int("rate", 20, "max messages per minute")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	writerFormat, found := tailFormats[*format]
+	if !found {
+		return usagef("unknown format %q", *format)
+	}
+
+	var re *regexp.Regexp
+	if *filter != "" {
+		var err error
+		if re, err = regexp.Compile(*filter); err != nil {
+			return usagef("invalid filter: %s", err)
+		}
+	}
+
+	client, err := rf.client(workrobot.WithRateLimit(*rate, time.Minute))
+	if err != nil {
+		return err
+	}
+
+	w := workrobot.NewWriter(client, workrobot.WithWriterFormat(writerFormat), workrobot.WithFlushInterval(*interval))
+	scanner := bufio.NewScanner(env.stdin)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if re != nil && !re.MatchString(scanner.Text()) {
+			continue
+		}
+
+		if _, err := io.WriteString(w, scanner.Text()+"\n"); err != nil {
+			_ = w.Close()
+			return err
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return errors.Wrap(scanner.Err(), "stdin unreadable")
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunTail(t *testing.T) {
+	var received []string
+	server := newTestServer(0, &received)
+	defer server.Close()
+
+	input := "INFO started\nERROR disk full\nINFO running\nERROR oom\n"
+	env, _ := newTestEnv(input, nil)
+	code := run(env, []string{"tail", "-webhook", server.URL, "-filter", "^ERROR", "-format", "quote"})
+	assert.Equal(t, exitOK, code)
+	if assert.Len(t, received, 1) {
+		assert.Contains(t, received[0], `"msgtype":"markdown"`)
+		assert.Contains(t, received[0], `ERROR disk full\n\u003e ERROR oom`)
+		assert.NotContains(t, received[0], "INFO")
+	}
+
+	env, _ = newTestEnv(strings.Repeat(strings.Repeat("x", 1000)+"\n", 3), nil)
+	assert.Equal(t, exitOK, run(env, []string{"tail", "-webhook", server.URL}))
+	if assert.Len(t, received, 3) {
+		assert.Contains(t, received[1], `"msgtype":"text"`)
+	}
+
+	env, _ = newTestEnv("", nil)
+	assert.Equal(t, exitUsage, run(env, []string{"tail", "-webhook", server.URL, "-format", "html"}))
+}
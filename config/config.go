@@ -0,0 +1,259 @@
+// Package config loads named robots from yaml, json or toml file and environment
+// variables, and builds a registry of ready clients looked up by name.
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// default prefix of environment variables
+	DefaultEnvPrefix = "WORKROBOT"
+)
+
+// supported formats of configuration file
+const (
+	FormatYAML = "yaml"
+	FormatJSON = "json"
+	FormatTOML = "toml"
+)
+
+// Duration represents a duration like 10s, 1m in configuration
+type Duration time.Duration
+
+// UnmarshalText parse the duration from text
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(v)
+	return nil
+}
+
+// MarshalText format the duration as text
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// RateLimit represents the rate limit of robot
+type RateLimit struct {
+	Count int      `yaml:"count" json:"count" toml:"count"`
+	Per   Duration `yaml:"per" json:"per" toml:"per"`
+}
+
+// Retry represents the retry policy of robot
+type Retry struct {
+	Attempts int      `yaml:"attempts" json:"attempts" toml:"attempts"`
+	Backoff  Duration `yaml:"backoff" json:"backoff" toml:"backoff"`
+}
+
+// Robot represents the configuration of a robot, one of Key, KeyFile
+// and Webhook is required
+type Robot struct {
+	Key string `yaml:"key,omitempty" json:"key,omitempty" toml:"key,omitempty"`
+	// KeyFile is the file contains the robot key, keeps key out of configuration
+	KeyFile string `yaml:"key_file,omitempty" json:"key_file,omitempty" toml:"key_file,omitempty"`
+	Webhook string `yaml:"webhook,omitempty" json:"webhook,omitempty" toml:"webhook,omitempty"`
+	// UploadGateway is the upload address of robot, derived from Webhook by default
+	UploadGateway string `yaml:"upload_gateway,omitempty" json:"upload_gateway,omitempty" toml:"upload_gateway,omitempty"`
+
+	Proxy     string     `yaml:"proxy,omitempty" json:"proxy,omitempty" toml:"proxy,omitempty"`
+	Timeout   Duration   `yaml:"timeout,omitempty" json:"timeout,omitempty" toml:"timeout,omitempty"`
+	RateLimit *RateLimit `yaml:"rate_limit,omitempty" json:"rate_limit,omitempty" toml:"rate_limit,omitempty"`
+	Retry     *Retry     `yaml:"retry,omitempty" json:"retry,omitempty" toml:"retry,omitempty"`
+}
+
+// Config represents the configuration of named robots
+type Config struct {
+	Robots map[string]*Robot `yaml:"robots" json:"robots" toml:"robots"`
+}
+
+// Parse parse configuration in the format
+func Parse(data []byte, format string) (*Config, error) {
+	var config Config
+	switch format {
+	case FormatYAML, FormatJSON:
+		// json is a subset of yaml
+		if err := yaml.Unmarshal(data, &config); err != nil {
+			return nil, errors.Wrap(err, "invalid config")
+		}
+	case FormatTOML:
+		if err := toml.Unmarshal(data, &config); err != nil {
+			return nil, errors.Wrap(err, "invalid config")
+		}
+	default:
+		return nil, errors.Errorf("unsupported config format %q", format)
+	}
+
+	if config.Robots == nil {
+		config.Robots = make(map[string]*Robot)
+	}
+	return &config, nil
+}
+
+// Load read configuration from file by its extension and apply
+// the overrides from environment variables with DefaultEnvPrefix
+func Load(filename string) (*Config, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot read config")
+	}
+
+	format := strings.TrimPrefix(strings.ToLower(filepath.Ext(filename)), ".")
+	if format == "yml" {
+		format = FormatYAML
+	}
+
+	config, err := Parse(data, format)
+	if err != nil {
+		return nil, err
+	}
+	return config, config.ApplyEnv(DefaultEnvPrefix)
+}
+
+// ApplyEnv override robots by environment variables like PREFIX_NAME_KEY, the name
+// is upper-cased and non-alphanumeric characters are replaced with underscore,
+// supported fields are KEY, KEY_FILE, WEBHOOK, UPLOAD_GATEWAY, PROXY, TIMEOUT,
+// RETRY_ATTEMPTS, RETRY_BACKOFF, RATE_LIMIT_COUNT and RATE_LIMIT_PER
+//
+// robots only defined in environment variables are recognized by PREFIX_NAME_KEY,
+// PREFIX_NAME_KEY_FILE or PREFIX_NAME_WEBHOOK with lower-cased name
+func (c *Config) ApplyEnv(prefix string) error {
+	for _, kv := range os.Environ() {
+		key := strings.SplitN(kv, "=", 2)[0]
+		if !strings.HasPrefix(key, prefix+"_") {
+			continue
+		}
+
+		for _, field := range []string{"_KEY", "_KEY_FILE", "_WEBHOOK"} {
+			if name := strings.TrimSuffix(key, field); name != key && name != prefix {
+				name = strings.ToLower(strings.TrimPrefix(name, prefix+"_"))
+				if c.lookup(prefix, name) == "" {
+					c.Robots[name] = &Robot{}
+				}
+			}
+		}
+	}
+
+	for name, robot := range c.Robots {
+		env := func(field string) (string, bool) {
+			return os.LookupEnv(envName(prefix, name, field))
+		}
+
+		if v, ok := env("KEY"); ok {
+			robot.Key = v
+		}
+		if v, ok := env("KEY_FILE"); ok {
+			robot.KeyFile = v
+		}
+		if v, ok := env("WEBHOOK"); ok {
+			robot.Webhook = v
+		}
+		if v, ok := env("UPLOAD_GATEWAY"); ok {
+			robot.UploadGateway = v
+		}
+		if v, ok := env("PROXY"); ok {
+			robot.Proxy = v
+		}
+		if v, ok := env("TIMEOUT"); ok {
+			if err := robot.Timeout.UnmarshalText([]byte(v)); err != nil {
+				return errors.Wrapf(err, "invalid timeout of %s", name)
+			}
+		}
+		if err := applyRetryEnv(robot, env); err != nil {
+			return errors.Wrapf(err, "invalid retry of %s", name)
+		}
+		if err := applyRateLimitEnv(robot, env); err != nil {
+			return errors.Wrapf(err, "invalid rate limit of %s", name)
+		}
+	}
+	return nil
+}
+
+// lookup returns the name of robot matches the environment name
+func (c *Config) lookup(prefix, name string) string {
+	for robot := range c.Robots {
+		if envName(prefix, robot, "") == envName(prefix, name, "") {
+			return robot
+		}
+	}
+	return ""
+}
+
+// applyRetryEnv override the retry policy of robot by environment variables
+func applyRetryEnv(robot *Robot, env func(string) (string, bool)) error {
+	attempts, hasAttempts := env("RETRY_ATTEMPTS")
+	backoff, hasBackoff := env("RETRY_BACKOFF")
+	if !hasAttempts && !hasBackoff {
+		return nil
+	}
+
+	if robot.Retry == nil {
+		robot.Retry = &Retry{}
+	}
+	if hasAttempts {
+		v, err := strconv.Atoi(attempts)
+		if err != nil {
+			return err
+		}
+		robot.Retry.Attempts = v
+	}
+	if hasBackoff {
+		return robot.Retry.Backoff.UnmarshalText([]byte(backoff))
+	}
+	return nil
+}
+
+// applyRateLimitEnv override the rate limit of robot by environment variables
+func applyRateLimitEnv(robot *Robot, env func(string) (string, bool)) error {
+	count, hasCount := env("RATE_LIMIT_COUNT")
+	per, hasPer := env("RATE_LIMIT_PER")
+	if !hasCount && !hasPer {
+		return nil
+	}
+
+	if robot.RateLimit == nil {
+		robot.RateLimit = &RateLimit{}
+	}
+	if hasCount {
+		v, err := strconv.Atoi(count)
+		if err != nil {
+			return err
+		}
+		robot.RateLimit.Count = v
+	}
+	if hasPer {
+		return robot.RateLimit.Per.UnmarshalText([]byte(per))
+	}
+	return nil
+}
+
+// envName build the environment variable name of robot field
+func envName(prefix, name, field string) string {
+	normalized := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+
+	if field == "" {
+		return prefix + "_" + normalized
+	}
+	return prefix + "_" + normalized + "_" + field
+}
@@ -0,0 +1,95 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoad(t *testing.T) {
+	for _, filename := range []string{"testdata/robots.yaml", "testdata/robots.json", "testdata/robots.toml"} {
+		config, err := Load(filename)
+		if assert.NoError(t, err, filename) {
+			assert.Contains(t, config.Robots, "ops", filename)
+			assert.NotZero(t, config.Robots["ops"].Timeout, filename)
+		}
+	}
+
+	config, _ := Load("testdata/robots.yaml")
+	ops := config.Robots["ops"]
+	assert.Equal(t, Duration(10*time.Second), ops.Timeout)
+	assert.Equal(t, &RateLimit{Count: 20, Per: Duration(time.Minute)}, ops.RateLimit)
+	assert.Equal(t, &Retry{Attempts: 3, Backoff: Duration(time.Second)}, ops.Retry)
+
+	_, err := Parse([]byte(""), "ini")
+	assert.Error(t, err)
+}
+
+func TestConfig_ApplyEnv(t *testing.T) {
+	envs := map[string]string{
+		"TEST_PAYMENT_TEAM_KEY":            "env-key",
+		"TEST_PAYMENT_TEAM_RETRY_ATTEMPTS": "5",
+		"TEST_DEPLOY_WEBHOOK":              "https://work.example.com/send?key=deploy",
+	}
+	for k, v := range envs {
+		_ = os.Setenv(k, v)
+	}
+	defer func() {
+		for k := range envs {
+			_ = os.Unsetenv(k)
+		}
+	}()
+
+	config, _ := Load("testdata/robots.yaml")
+	if assert.NoError(t, config.ApplyEnv("TEST")) {
+		assert.Equal(t, "env-key", config.Robots["payment-team"].Key)
+		assert.Equal(t, 5, config.Robots["payment-team"].Retry.Attempts)
+		assert.Equal(t, "https://work.example.com/send?key=deploy", config.Robots["deploy"].Webhook)
+	}
+}
+
+func TestConfig_Registry(t *testing.T) {
+	config, _ := Load("testdata/robots.yaml")
+	registry, err := config.Registry()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, []string{"ops", "payment-team"}, registry.Names())
+	_, err = registry.Get("payment-team")
+	assert.NoError(t, err)
+	_, err = registry.Get("missing")
+	assert.Error(t, err)
+
+	config.Robots["broken"] = &Robot{}
+	_, err = config.Registry()
+	assert.Error(t, err)
+}
+
+func TestRobot_Client_Upload(t *testing.T) {
+	var uploaded []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		uploaded = append(uploaded, r.URL.Path+"?"+r.URL.RawQuery)
+		_, _ = fmt.Fprint(w, `{"errcode":0,"errmsg":"ok","type":"file","media_id":"media-1","created_at":"1380000000"}`)
+	}))
+	defer server.Close()
+
+	robots := []*Robot{
+		{Webhook: server.URL + "/webhook/send?key=hook-key"},
+		{Key: "ops-key", UploadGateway: server.URL + "/upload"},
+	}
+	for _, robot := range robots {
+		client, err := robot.Client()
+		if assert.NoError(t, err) {
+			_, err = client.Uploader().UploadFromBytes("a.txt", []byte("a"))
+			assert.NoError(t, err)
+		}
+	}
+
+	assert.Equal(t, []string{"/webhook/upload_media?key=hook-key&type=file", "/upload?key=ops-key&type=file"}, uploaded)
+}
@@ -0,0 +1,113 @@
+package config
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/wjiec/workrobot"
+
+	"github.com/pkg/errors"
+)
+
+// Registry represents the ready clients of named robots
+type Registry struct {
+	clients map[string]*workrobot.Client
+}
+
+// Get returns the client of robot by name
+func (r *Registry) Get(name string) (*workrobot.Client, error) {
+	if c, found := r.clients[name]; found {
+		return c, nil
+	}
+	return nil, errors.Errorf("unknown robot %q", name)
+}
+
+// Names returns the names of all robots in order
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.clients))
+	for name := range r.clients {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Clients returns the clients of all robots by name
+func (r *Registry) Clients() map[string]*workrobot.Client {
+	clients := make(map[string]*workrobot.Client, len(r.clients))
+	for name, c := range r.clients {
+		clients[name] = c
+	}
+	return clients
+}
+
+// Registry builds clients of all robots, the options are applied to
+// each client after the configured ones
+func (c *Config) Registry(options ...workrobot.ClientOption) (*Registry, error) {
+	r := &Registry{clients: make(map[string]*workrobot.Client, len(c.Robots))}
+	for name, robot := range c.Robots {
+		client, err := robot.Client(options...)
+		if err != nil {
+			return nil, errors.Wrapf(err, "robot %s", name)
+		}
+		r.clients[name] = client
+	}
+	return r, nil
+}
+
+// Client builds the client of robot, the options are applied after the configured ones
+func (r *Robot) Client(options ...workrobot.ClientOption) (*workrobot.Client, error) {
+	key := r.Key
+	if r.KeyFile != "" {
+		bs, err := ioutil.ReadFile(r.KeyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot read key file")
+		}
+		key = strings.TrimSpace(string(bs))
+	}
+	if key == "" && r.Webhook == "" {
+		return nil, errors.New("required key, key_file or webhook")
+	}
+
+	var opts []workrobot.ClientOption
+	if r.Webhook != "" {
+		opts = append(opts, workrobot.WithWebhook(r.Webhook))
+	}
+	if r.UploadGateway != "" {
+		opts = append(opts, workrobot.WithUploadGateway(r.UploadGateway))
+	}
+	if r.Proxy != "" || r.Timeout != 0 {
+		hc, err := r.httpClient()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, workrobot.WithHttpClient(hc))
+	}
+	if r.RateLimit != nil {
+		opts = append(opts, workrobot.WithRateLimit(r.RateLimit.Count, time.Duration(r.RateLimit.Per)))
+	}
+	if r.Retry != nil {
+		opts = append(opts, workrobot.WithRetry(r.Retry.Attempts, time.Duration(r.Retry.Backoff)))
+	}
+
+	return workrobot.NewClient(key, append(opts, options...)...)
+}
+
+// httpClient build the http client with proxy and timeout
+func (r *Robot) httpClient() (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if r.Proxy != "" {
+		proxy, err := url.Parse(r.Proxy)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid proxy")
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	return &http.Client{Transport: transport, Timeout: time.Duration(r.Timeout)}, nil
+}
@@ -0,0 +1,225 @@
+// Package crash reports panics to robot, the report contains the stack trace,
+// hostname, build info and the request info when recovered by the middleware.
+//
+//	reporter := crash.New(client, crash.WithTitle("api"))
+//	defer reporter.Recover()
+//
+//	http.ListenAndServe(":8080", reporter.Middleware(mux))
+package crash
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/wjiec/workrobot"
+	md "github.com/wjiec/workrobot/markdown"
+)
+
+// Report represents the information of a panic
+type Report struct {
+	Time     time.Time
+	Value    interface{}
+	Stack    string
+	Hostname string
+	Build    string
+	Request  *http.Request
+}
+
+// Text returns the full report in plain text
+func (r *Report) Text() string {
+	var sb strings.Builder
+	_, _ = fmt.Fprintf(&sb, "panic: %v\n\n", r.Value)
+	_, _ = fmt.Fprintf(&sb, "time: %s\nhost: %s\nbuild: %s\n", r.Time.Format(time.RFC3339), r.Hostname, r.Build)
+	if r.Request != nil {
+		_, _ = fmt.Fprintf(&sb, "request: %s\n", request(r.Request))
+	}
+	_, _ = fmt.Fprintf(&sb, "\n%s", r.Stack)
+	return sb.String()
+}
+
+// Markdown renders the report as markdown, the stack is trimmed to fit in
+// the message, and trimmed reports whether the stack is trimmed
+func (r *Report) Markdown(title string) (msg *workrobot.Markdown, trimmed bool, err error) {
+	header := md.Bold(md.ColorOrangeRed(fmt.Sprintf("panic: %v", r.Value)))
+	if title != "" {
+		header = md.Join(" ", md.Bold("["+title+"]"), header)
+	}
+
+	lines := []interface{}{
+		firstLine(header.String(), 512),
+		md.Quote("host: " + r.Hostname),
+		md.Quote("build: " + r.Build),
+	}
+	if r.Request != nil {
+		lines = append(lines, md.Quote("request: "+firstLine(request(r.Request), 512)))
+	}
+
+	const notice = "... stack trimmed"
+	// each line is counted with its newline, including the notice
+	size := len(notice) + 1 + len(lines)
+	for _, line := range lines {
+		size += len(fmt.Sprint(line))
+	}
+
+	for _, line := range strings.Split(strings.TrimSpace(r.Stack), "\n") {
+		line = md.ColorGray(strings.Replace(line, "\t", "    ", 1)).String()
+		if size += len(line) + 1; size > workrobot.MarkdownMessageMaxLength {
+			lines, trimmed = append(lines, notice), true
+			break
+		}
+		lines = append(lines, line)
+	}
+
+	msg, err = workrobot.NewMarkdown(lines...)
+	return msg, trimmed, err
+}
+
+// Reporter represents a reporter sends panics to robot
+type Reporter struct {
+	client      *workrobot.Client
+	title       string
+	timeout     time.Duration
+	uploadStack bool
+	onError     func(error)
+	hostname    string
+	build       string
+}
+
+// Recover recovers the panic, reports it and panics again, it must be
+// called directly by defer
+func (r *Reporter) Recover() {
+	if v := recover(); v != nil {
+		r.report(v, nil)
+		panic(v)
+	}
+}
+
+// Middleware returns a handler recovers panics of next, reports them with
+// the request info and responds 500
+func (r *Reporter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		defer func() {
+			if v := recover(); v != nil {
+				if v == http.ErrAbortHandler {
+					panic(v)
+				}
+
+				r.report(v, req)
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			}
+		}()
+
+		next.ServeHTTP(w, req)
+	})
+}
+
+// report builds and sends the report of panic value
+func (r *Reporter) report(v interface{}, req *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	report := &Report{Time: time.Now(), Value: v, Stack: string(debug.Stack()),
+		Hostname: r.hostname, Build: r.build, Request: req}
+	if err := r.Send(ctx, report); err != nil && r.onError != nil {
+		r.onError(err)
+	}
+}
+
+// Send sends the report to robot, the full report is uploaded as a file
+// when the stack is trimmed and WithUploadStack enabled
+func (r *Reporter) Send(ctx context.Context, report *Report) error {
+	msg, trimmed, err := report.Markdown(r.title)
+	if err != nil {
+		return err
+	}
+	if err := r.client.SendContext(ctx, msg); err != nil {
+		return err
+	}
+	if !trimmed || !r.uploadStack {
+		return nil
+	}
+
+	filename := fmt.Sprintf("panic-%s.txt", report.Time.Format("20060102-150405"))
+	media, err := r.client.Uploader().UploadFromBytesContext(ctx, filename, []byte(report.Text()))
+	if err != nil {
+		return err
+	}
+	return r.client.SendContext(ctx, workrobot.NewMedia(media.Id))
+}
+
+// request returns the summary of request
+func request(req *http.Request) string {
+	return fmt.Sprintf("%s %s from %s (%s)", req.Method, req.URL.RequestURI(), req.RemoteAddr, req.UserAgent())
+}
+
+// firstLine returns the first line of s at most n bytes
+func firstLine(s string, n int) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[:i]
+	}
+	if len(s) > n {
+		s = strings.ToValidUTF8(s[:n], "") + "..."
+	}
+	return s
+}
+
+// buildInfo returns the summary of build info
+func buildInfo() string {
+	parts := []string{runtime.Version(), runtime.GOOS + "/" + runtime.GOARCH}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		parts = append(parts, info.Main.Path+"@"+info.Main.Version)
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				parts = append(parts, "rev "+setting.Value)
+			}
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// Option represents additional reporter configuration
+type Option func(*Reporter)
+
+// WithTitle sets the title of reports, like the service name
+func WithTitle(title string) Option {
+	return func(r *Reporter) {
+		r.title = title
+	}
+}
+
+// WithTimeout sets the timeout of sending a report, defaults to 5 seconds
+func WithTimeout(timeout time.Duration) Option {
+	return func(r *Reporter) {
+		r.timeout = timeout
+	}
+}
+
+// WithUploadStack uploads the full report as a file when the stack is trimmed
+func WithUploadStack(upload bool) Option {
+	return func(r *Reporter) {
+		r.uploadStack = upload
+	}
+}
+
+// WithErrorHandler sets the handler of errors when sending reports
+func WithErrorHandler(fn func(error)) Option {
+	return func(r *Reporter) {
+		r.onError = fn
+	}
+}
+
+// New create a reporter sends panics to client
+func New(client *workrobot.Client, options ...Option) *Reporter {
+	hostname, _ := os.Hostname()
+	r := &Reporter{client: client, timeout: 5 * time.Second, hostname: hostname, build: buildInfo()}
+	for _, opt := range options {
+		opt(r)
+	}
+	return r
+}
@@ -0,0 +1,96 @@
+package crash
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wjiec/workrobot"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestClient(t *testing.T) (*workrobot.Client, *[]string) {
+	var received []string
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "upload_media") {
+			_, _ = fmt.Fprint(w, `{"errcode":0,"errmsg":"ok","type":"file","media_id":"media-1","created_at":"1380000000"}`)
+			return
+		}
+
+		bs, _ := ioutil.ReadAll(r.Body)
+		received = append(received, string(bs))
+		_, _ = fmt.Fprint(w, `{"errcode":0,"errmsg":"ok"}`)
+	}))
+	t.Cleanup(gateway.Close)
+
+	client, _ := workrobot.NewClient("key", workrobot.WithWebhook(gateway.URL+"/send"),
+		workrobot.WithUploadGateway(gateway.URL+"/upload_media"))
+	return client, &received
+}
+
+func TestReporter_Recover(t *testing.T) {
+	client, received := newTestClient(t)
+	reporter := New(client, WithTitle("api"))
+
+	assert.PanicsWithValue(t, "boom", func() {
+		defer reporter.Recover()
+		panic("boom")
+	})
+	if assert.Len(t, *received, 1) {
+		assert.Contains(t, (*received)[0], "[api]")
+		assert.Contains(t, (*received)[0], "panic: boom")
+		assert.Contains(t, (*received)[0], "TestReporter_Recover")
+	}
+
+	assert.NotPanics(t, func() {
+		defer reporter.Recover()
+	})
+	assert.Len(t, *received, 1)
+}
+
+func TestReporter_Middleware(t *testing.T) {
+	client, received := newTestClient(t)
+	handler := New(client).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(fmt.Errorf("nil map"))
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users?id=1", nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	if assert.Len(t, *received, 1) {
+		assert.Contains(t, (*received)[0], "request: GET /users?id=1 from 192.0.2.1:1234")
+	}
+}
+
+func TestReporter_Send(t *testing.T) {
+	client, received := newTestClient(t)
+	reporter := New(client, WithUploadStack(true))
+
+	report := &Report{Time: time.Now(), Value: "boom", Stack: strings.Repeat("main.main()\n\t/app/main.go:10\n", 500)}
+	msg, trimmed, err := report.Markdown("")
+	if assert.NoError(t, err) {
+		assert.True(t, trimmed)
+		assert.Contains(t, string(msg.Message()), "stack trimmed")
+	}
+
+	assert.NoError(t, reporter.Send(context.Background(), report))
+	if assert.Len(t, *received, 2) {
+		assert.Contains(t, (*received)[1], `"media_id":"media-1"`)
+	}
+}
+
+func TestReport_MarkdownBoundary(t *testing.T) {
+	stack := strings.Repeat("main.main()\n\t/app/main.go:10\n", 100)
+	for n := 1; n <= 100; n++ {
+		report := &Report{Time: time.Now(), Value: strings.Repeat("x", n), Hostname: "host", Build: "v1", Stack: stack}
+		_, trimmed, err := report.Markdown("api")
+		assert.NoError(t, err, "value of %d bytes", n)
+		assert.True(t, trimmed)
+	}
+}
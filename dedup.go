@@ -0,0 +1,136 @@
+package workrobot
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	md "github.com/wjiec/workrobot/markdown"
+)
+
+// dedupKeyContext represents the context key of caller-supplied dedup key
+type dedupKeyContext struct{}
+
+// WithDedupKey returns a context with the deduplication key, messages sent with
+// the same key are duplicates regardless of their content
+func WithDedupKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, dedupKeyContext{}, key)
+}
+
+// DedupSummary build a summary message of the suppressed duplicates
+type DedupSummary func(msg Messager, suppressed int, window time.Duration) Messager
+
+// DefaultDedupSummary build a markdown summary with the content of duplicated message
+func DefaultDedupSummary(msg Messager, suppressed int, window time.Duration) Messager {
+	summary, _ := NewMarkdown(md.ColorGray(fmt.Sprintf("suppressed %d duplicates in %s", suppressed, window)))
+
+	var data payload
+	_ = json.Unmarshal(msg.Message(), &data)
+	switch {
+	case data.Text != nil && data.Text.Content != "":
+		_ = summary.AddSegmentLine(md.Quote(data.Text.Content))
+	case data.Markdown != nil && data.Markdown.Content != "":
+		_ = summary.AddSegmentLine(md.Quote(data.Markdown.Content))
+	}
+
+	return summary
+}
+
+// dedupEntry represents a delivered message and its duplicates within window,
+// the done is closed when the first send finished with err
+type dedupEntry struct {
+	msg        Messager
+	suppressed int
+	done       chan struct{}
+	err        error
+}
+
+// deduplicator represents the deduplication state of robot
+type deduplicator struct {
+	mu      sync.Mutex
+	entries map[string]*dedupEntry
+
+	client  *Client
+	window  time.Duration
+	summary DedupSummary
+}
+
+// intercept suppress the message when it duplicates a message within window,
+// the duplicates sent before the first send finished get the same error
+func (d *deduplicator) intercept(ctx context.Context, call *Call, next Handler) error {
+	key, _ := ctx.Value(dedupKeyContext{}).(string)
+	if key == "" {
+		key = fmt.Sprintf("%x", sha256.Sum256(call.Payload()))
+	}
+
+	d.mu.Lock()
+	if entry, found := d.entries[key]; found {
+		entry.suppressed++
+		d.mu.Unlock()
+
+		select {
+		case <-entry.done:
+			return entry.err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	entry := &dedupEntry{msg: call.Message, done: make(chan struct{})}
+	d.entries[key] = entry
+	d.mu.Unlock()
+
+	if entry.err = next(ctx, call); entry.err != nil {
+		// nothing delivered, the duplicates are not summarized
+		d.mu.Lock()
+		delete(d.entries, key)
+		d.mu.Unlock()
+	} else {
+		time.AfterFunc(d.window, func() { d.expire(key) })
+	}
+
+	close(entry.done)
+	return entry.err
+}
+
+// expire close the window of key and sends summary when duplicates suppressed
+func (d *deduplicator) expire(key string) {
+	d.mu.Lock()
+	entry := d.entries[key]
+	delete(d.entries, key)
+	d.mu.Unlock()
+
+	if entry != nil && entry.suppressed != 0 && d.summary != nil {
+		// summary is best-effort, and there is nobody to report error, it is
+		// sent directly without interceptors and throttling
+		msg := d.summary(entry.msg, entry.suppressed, d.window)
+		_ = d.client.handler(false)(context.Background(), &Call{Message: msg, Webhook: d.client.webhook})
+	}
+}
+
+// DedupOption represents additional deduplication configuration
+type DedupOption func(*deduplicator)
+
+// WithDedupSummary sends a summary message built by fn when the window
+// closes and there are duplicates suppressed
+func WithDedupSummary(fn DedupSummary) DedupOption {
+	return func(d *deduplicator) {
+		d.summary = fn
+	}
+}
+
+// WithDeduplication suppress the messages with the same payload, or the same
+// key from WithDedupKey, when they are sent repeatedly within the window
+func WithDeduplication(window time.Duration, options ...DedupOption) ClientOption {
+	return func(client *Client) error {
+		d := &deduplicator{entries: make(map[string]*dedupEntry), client: client, window: window}
+		for _, opt := range options {
+			opt(d)
+		}
+
+		client.interceptors = append(client.interceptors, d.intercept)
+		return nil
+	}
+}
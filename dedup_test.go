@@ -0,0 +1,170 @@
+package workrobot
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithDeduplication(t *testing.T) {
+	var mu sync.Mutex
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bs, _ := ioutil.ReadAll(r.Body)
+		mu.Lock()
+		received = append(received, string(bs))
+		mu.Unlock()
+		_, _ = fmt.Fprint(w, `{"errcode":0,"errmsg":"ok"}`)
+	}))
+	defer server.Close()
+
+	window := 50 * time.Millisecond
+	c, _ := NewClient("", WithWebhook(server.URL), WithDeduplication(window, WithDedupSummary(DefaultDedupSummary)))
+
+	disk, _ := NewText("disk full")
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, c.Send(disk))
+	}
+
+	cpu, _ := NewText("cpu high")
+	ctx := WithDedupKey(context.Background(), "host-1")
+	assert.NoError(t, c.SendContext(ctx, cpu))
+	assert.NoError(t, c.SendContext(ctx, disk))
+
+	mu.Lock()
+	assert.Len(t, received, 2)
+	mu.Unlock()
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(received) == 4
+	}, 10*window, window/5)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Contains(t, received[2]+received[3], "suppressed 2 duplicates")
+	assert.Contains(t, received[2]+received[3], "suppressed 1 duplicates")
+}
+
+func TestWithDeduplication_Failed(t *testing.T) {
+	var sent int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent++
+		_, _ = fmt.Fprint(w, `{"errcode":45009,"errmsg":"api freq out of limit"}`)
+	}))
+	defer server.Close()
+
+	c, _ := NewClient("", WithWebhook(server.URL), WithDeduplication(time.Minute))
+
+	text, _ := NewText("retry me")
+	assert.Error(t, c.Send(text))
+	assert.Error(t, c.Send(text))
+	assert.Equal(t, 2, sent)
+}
+
+func TestWithDeduplication_FailedInFlight(t *testing.T) {
+	arrived, release := make(chan struct{}), make(chan struct{})
+	var mu sync.Mutex
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bs, _ := ioutil.ReadAll(r.Body)
+		mu.Lock()
+		received = append(received, string(bs))
+		first := len(received) == 1
+		mu.Unlock()
+
+		if first {
+			close(arrived)
+			<-release
+			_, _ = fmt.Fprint(w, `{"errcode":45009,"errmsg":"api freq out of limit"}`)
+			return
+		}
+		_, _ = fmt.Fprint(w, `{"errcode":0,"errmsg":"ok"}`)
+	}))
+	defer server.Close()
+
+	c, _ := NewClient("", WithWebhook(server.URL))
+	d := &deduplicator{entries: make(map[string]*dedupEntry), client: c, window: time.Minute, summary: DefaultDedupSummary}
+
+	var intercepted int
+	c.interceptors = append(c.interceptors, d.intercept, func(ctx context.Context, call *Call, next Handler) error {
+		intercepted++
+		return next(ctx, call)
+	})
+
+	text, _ := NewText("retry me")
+	first, duplicate := make(chan error), make(chan error)
+	go func() { first <- c.Send(text) }()
+
+	<-arrived
+	go func() { duplicate <- c.Send(text) }()
+	assert.Eventually(t, func() bool {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		for _, entry := range d.entries {
+			return entry.suppressed == 1
+		}
+		return false
+	}, time.Second, time.Millisecond)
+	close(release)
+
+	var receipt *Receipt
+	assert.ErrorAs(t, <-first, &receipt)
+	assert.ErrorAs(t, <-duplicate, &receipt)
+
+	mu.Lock()
+	assert.Len(t, received, 1) // no summary for the undelivered message
+	mu.Unlock()
+
+	assert.NoError(t, c.Send(text))
+	mu.Lock()
+	assert.Len(t, received, 2)
+	mu.Unlock()
+	assert.Equal(t, 2, intercepted)
+}
+
+func TestWithDeduplication_Summary(t *testing.T) {
+	var mu sync.Mutex
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bs, _ := ioutil.ReadAll(r.Body)
+		mu.Lock()
+		received = append(received, string(bs))
+		mu.Unlock()
+		_, _ = fmt.Fprint(w, `{"errcode":0,"errmsg":"ok"}`)
+	}))
+	defer server.Close()
+
+	var mi sync.Mutex
+	var intercepted int
+	window := 20 * time.Millisecond
+	c, _ := NewClient("", WithWebhook(server.URL), WithDeduplication(window, WithDedupSummary(DefaultDedupSummary)),
+		WithInterceptor(func(ctx context.Context, call *Call, next Handler) error {
+			mi.Lock()
+			intercepted++
+			mi.Unlock()
+			return next(ctx, call)
+		}))
+
+	text, _ := NewText("disk full")
+	assert.NoError(t, c.Send(text))
+	assert.NoError(t, c.Send(text))
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(received) == 2
+	}, 10*window, window/5)
+
+	mi.Lock()
+	defer mi.Unlock()
+	assert.Equal(t, 1, intercepted) // the summary is not intercepted
+}
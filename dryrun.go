@@ -0,0 +1,97 @@
+package workrobot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	uploader "github.com/wjiec/workrobot/media"
+
+	"github.com/pkg/errors"
+)
+
+// dryRun represents the dry-run configuration of robot
+type dryRun struct {
+	mu sync.Mutex
+	w  io.Writer
+
+	allow map[string]bool
+}
+
+// allowed checks whether the robot is still sent, by its key, the key in the
+// webhook or the webhook address
+func (d *dryRun) allowed(c *Client) bool {
+	for _, key := range []string{c.key, webhookKey(c.webhook), c.webhook} {
+		if key != "" && d.allow[key] {
+			return true
+		}
+	}
+	return false
+}
+
+// transmit renders the message of call instead of sending it, and records a
+// success receipt
+func (d *dryRun) transmit(ctx context.Context, call *Call) error {
+	call.Attempt++
+	if call.Dispatched.IsZero() {
+		call.Dispatched = time.Now()
+	}
+
+	if err := d.render(call); err != nil {
+		return err
+	}
+
+	call.Receipt = &Receipt{Code: 0, Message: "ok"}
+	return nil
+}
+
+// render validates the message payload and writes it to the writer
+func (d *dryRun) render(call *Call) error {
+	payload := call.Payload()
+	if !json.Valid(payload) || call.Type() == "" {
+		return errors.New("invalid message payload")
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, err := fmt.Fprintf(d.w, "dry-run %s: %s\n", call.Type(), payload); err != nil {
+		return errors.Wrap(err, "dry-run output not writable")
+	}
+	return nil
+}
+
+// upload writes the summary of upload to the writer instead of uploading the
+// file, and returns a placeholder media
+func (d *dryRun) upload(ctx context.Context, up *uploader.Upload, next uploader.Handler) error {
+	up.Attempt++
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, err := fmt.Fprintf(d.w, "dry-run upload: %s (%d bytes)\n", up.Filename, up.Size); err != nil {
+		return errors.Wrap(err, "dry-run output not writable")
+	}
+
+	up.Media = &uploader.Media{Id: "dry-run", Type: "file", CreatedAt: time.Now().Unix()}
+	return nil
+}
+
+// WithDryRun make robot render the messages into writer instead of sending them,
+// and returns a success receipt, the uploads are written as summaries with a
+// placeholder media, the robots allowed by their keys or webhook addresses are
+// still sent
+//
+// a log.Logger can be used by its Writer method
+func WithDryRun(w io.Writer, allow ...string) ClientOption {
+	return func(client *Client) error {
+		client.dryRun = &dryRun{w: w, allow: make(map[string]bool)}
+		for _, key := range allow {
+			client.dryRun.allow[key] = true
+		}
+		return nil
+	}
+}
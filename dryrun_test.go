@@ -0,0 +1,102 @@
+package workrobot
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	uploader "github.com/wjiec/workrobot/media"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithDryRun(t *testing.T) {
+	var sent int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent++
+		_, _ = fmt.Fprint(w, `{"errcode":0,"errmsg":"ok"}`)
+	}))
+	defer server.Close()
+
+	var out bytes.Buffer
+	dry, _ := NewClient("staging", WithWebhook(server.URL), WithDryRun(&out, "allowed"))
+	allowed, _ := NewClient("allowed", WithWebhook(server.URL), WithDryRun(&out, "allowed"))
+
+	text, _ := NewText("hello dry-run")
+	assert.NoError(t, dry.Send(text))
+	assert.Equal(t, 0, sent)
+	assert.Contains(t, out.String(), "dry-run text:")
+	assert.Contains(t, out.String(), "hello dry-run")
+
+	assert.NoError(t, allowed.Send(text))
+	assert.Equal(t, 1, sent)
+
+	byWebhook, _ := NewClient("", WithWebhook(server.URL+"/send"), WithDryRun(&out, server.URL+"/send"))
+	assert.NoError(t, byWebhook.Send(text))
+	assert.Equal(t, 2, sent)
+
+	byQuery, _ := NewClient("", WithWebhook(server.URL+"/send?key=allowed"), WithDryRun(&out, "allowed"))
+	assert.NoError(t, byQuery.Send(text))
+	assert.Equal(t, 3, sent)
+}
+
+func TestWithDryRun_Bypass(t *testing.T) {
+	var out bytes.Buffer
+	var attempts []int
+	c, _ := NewClient("staging", WithDryRun(&out), WithRateLimit(1, time.Hour), WithRetry(3, time.Hour),
+		WithInterceptor(func(ctx context.Context, call *Call, next Handler) error {
+			err := next(ctx, call)
+			attempts = append(attempts, call.Attempt)
+			return err
+		}))
+
+	text, _ := NewText("not throttled")
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 3; i++ {
+			assert.NoError(t, c.Send(text))
+		}
+		assert.Error(t, c.Send(brokenMessage{}))
+	}()
+
+	select {
+	case <-done:
+		assert.Equal(t, []int{1, 1, 1, 1}, attempts)
+	case <-time.After(time.Second):
+		t.Fatal("dry-run messages are throttled or retried")
+	}
+}
+
+type brokenMessage struct{}
+
+func (brokenMessage) Message() []byte { return []byte("{") }
+
+func TestWithDryRun_Invalid(t *testing.T) {
+	var out bytes.Buffer
+	c, _ := NewClient("staging", WithDryRun(&out))
+
+	assert.Error(t, c.Send(brokenMessage{}))
+	assert.Empty(t, out.String())
+}
+
+func TestWithDryRun_Upload(t *testing.T) {
+	var out bytes.Buffer
+	var uploads int
+	c, _ := NewClient("staging", WithDryRun(&out), WithUploadInterceptor(
+		func(ctx context.Context, up *uploader.Upload, next uploader.Handler) error {
+			uploads++
+			return next(ctx, up)
+		}))
+
+	media, err := c.Uploader().UploadFromBytes("report.txt", []byte("hello"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "dry-run", media.Id)
+	}
+	assert.Equal(t, 1, uploads)
+	assert.Equal(t, "dry-run upload: report.txt (5 bytes)\n", out.String())
+}
@@ -0,0 +1,259 @@
+// Package grafana implements a receiver of grafana unified alerting webhook,
+// alerts are rendered into card or markdown messages with links back to the
+// dashboard and panel.
+//
+// see https://grafana.com/docs/grafana/latest/alerting/configure-notifications/manage-contact-points/integrations/webhook-notifier/
+package grafana
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/wjiec/workrobot"
+	"github.com/wjiec/workrobot/internal/strutil"
+	"github.com/wjiec/workrobot/internal/webhook"
+	md "github.com/wjiec/workrobot/markdown"
+)
+
+const (
+	// max length of alert description in markdown
+	maxDescriptionLength = 1024
+	// max length of notification title and alert summary in markdown
+	maxSummaryLength = 256
+	// max length of article title and description in news card
+	maxArticleTitleLength       = 128
+	maxArticleDescriptionLength = 512
+)
+
+// Alert represents an alert of grafana webhook notification
+type Alert struct {
+	Status       string             `json:"status"`
+	Labels       map[string]string  `json:"labels"`
+	Annotations  map[string]string  `json:"annotations"`
+	StartsAt     time.Time          `json:"startsAt"`
+	EndsAt       time.Time          `json:"endsAt"`
+	Values       map[string]float64 `json:"values"`
+	ValueString  string             `json:"valueString"`
+	GeneratorURL string             `json:"generatorURL"`
+	Fingerprint  string             `json:"fingerprint"`
+	SilenceURL   string             `json:"silenceURL"`
+	DashboardURL string             `json:"dashboardURL"`
+	PanelURL     string             `json:"panelURL"`
+	ImageURL     string             `json:"imageURL"`
+}
+
+// Name returns the alertname label of alert
+func (a *Alert) Name() string {
+	return a.Labels["alertname"]
+}
+
+// Link returns the most specific link of alert, the panel, dashboard or rule
+func (a *Alert) Link() string {
+	for _, link := range []string{a.PanelURL, a.DashboardURL, a.GeneratorURL} {
+		if link != "" {
+			return link
+		}
+	}
+	return ""
+}
+
+// Notification represents the webhook notification of an alert group
+type Notification struct {
+	Receiver          string            `json:"receiver"`
+	Status            string            `json:"status"`
+	OrgId             int64             `json:"orgId"`
+	Alerts            []*Alert          `json:"alerts"`
+	GroupLabels       map[string]string `json:"groupLabels"`
+	CommonLabels      map[string]string `json:"commonLabels"`
+	CommonAnnotations map[string]string `json:"commonAnnotations"`
+	ExternalURL       string            `json:"externalURL"`
+	Version           string            `json:"version"`
+	GroupKey          string            `json:"groupKey"`
+	TruncatedAlerts   int               `json:"truncatedAlerts"`
+	Title             string            `json:"title"`
+	State             string            `json:"state"`
+	Message           string            `json:"message"`
+}
+
+// Format represents the message format of alerts
+type Format int
+
+const (
+	// FormatMarkdown renders alerts into markdown messages
+	FormatMarkdown Format = iota
+	// FormatCard renders alerts into news card with images
+	FormatCard
+)
+
+// Receiver represents a webhook receiver sends grafana alerts to robots
+type Receiver struct {
+	clients  map[string]*workrobot.Client
+	fallback *workrobot.Client
+	format   Format
+}
+
+// ServeHTTP implements http.Handler
+func (r *Receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var n Notification
+	webhook.Serve(w, req, &n, func() (*workrobot.Client, []workrobot.Messager, error) {
+		client := r.clients[n.Receiver]
+		if client == nil {
+			client = r.fallback
+		}
+		if client == nil {
+			return nil, nil, webhook.WithStatus(http.StatusNotFound, fmt.Errorf("unknown receiver %s", n.Receiver))
+		}
+
+		messages, err := r.Render(&n)
+		return client, messages, err
+	})
+}
+
+// Render renders the notification into messages
+func (r *Receiver) Render(n *Notification) ([]workrobot.Messager, error) {
+	if r.format == FormatCard {
+		return renderCards(n)
+	}
+	return renderMarkdown(n)
+}
+
+// renderMarkdown renders the notification into as few markdown messages as possible
+func renderMarkdown(n *Notification) ([]workrobot.Messager, error) {
+	title := n.Title
+	if title == "" {
+		title = fmt.Sprintf("[%s] %s", strings.ToUpper(n.Status), n.CommonLabels["alertname"])
+	}
+
+	header, err := workrobot.NewMarkdown(md.Bold(status(n.Status, strutil.Truncate(title, maxSummaryLength))))
+	if err != nil {
+		return nil, err
+	}
+
+	messages := []workrobot.Messager{header}
+	for _, alert := range n.Alerts {
+		var block workrobot.Markdown
+		if err := block.RawContent(strings.Join(alertLines(alert), "\n")); err != nil {
+			return nil, err
+		}
+		messages = append(messages, &block)
+	}
+	return workrobot.Coalesce(messages...), nil
+}
+
+// alertLines build the markdown lines of alert, the summary, description and
+// values are truncated, and links not fit in a message are dropped
+func alertLines(alert *Alert) []string {
+	summary := strutil.Truncate(strutil.FirstOf(alert.Annotations["summary"], alert.Name()), maxSummaryLength)
+	lines := []string{md.Join(" ", status(alert.Status, alert.Status), summary).String()}
+	if description := alert.Annotations["description"]; description != "" {
+		lines = append(lines, md.Quote(strutil.Truncate(description, maxDescriptionLength)).String())
+	}
+	if alert.ValueString != "" {
+		lines = append(lines, md.ColorGray(strutil.Truncate(alert.ValueString, maxDescriptionLength)).String())
+	} else if len(alert.Values) != 0 {
+		lines = append(lines, md.ColorGray(strutil.Truncate(values(alert.Values), maxDescriptionLength)).String())
+	}
+
+	var links []interface{}
+	size := len(strings.Join(lines, "\n")) + 1
+	for _, link := range [][2]string{
+		{"Dashboard", alert.DashboardURL}, {"Panel", alert.PanelURL},
+		{"Source", alert.GeneratorURL}, {"Silence", alert.SilenceURL}, {"Image", alert.ImageURL},
+	} {
+		if link[1] == "" {
+			continue
+		}
+
+		segment := md.Link(link[0], link[1])
+		if size+len(" | ")+len(segment.String()) > workrobot.MarkdownMessageMaxLength {
+			continue
+		}
+		size += len(" | ") + len(segment.String())
+		links = append(links, segment)
+	}
+	if len(links) != 0 {
+		lines = append(lines, md.Join(" | ", links...).String())
+	}
+
+	return lines
+}
+
+// renderCards renders alerts into news cards, each alert is an article
+func renderCards(n *Notification) ([]workrobot.Messager, error) {
+	var messages []workrobot.Messager
+	for i := 0; i < len(n.Alerts); i += workrobot.MaxArticleCount {
+		card, err := workrobot.NewCard()
+		if err != nil {
+			return nil, err
+		}
+
+		end := i + workrobot.MaxArticleCount
+		if end > len(n.Alerts) {
+			end = len(n.Alerts)
+		}
+
+		for _, alert := range n.Alerts[i:end] {
+			err := card.AddArticle(&workrobot.Article{
+				Title: strutil.Truncate(fmt.Sprintf("[%s] %s", strings.ToUpper(alert.Status),
+					strutil.FirstOf(alert.Annotations["summary"], alert.Name())), maxArticleTitleLength),
+				Description: strutil.Truncate(strutil.FirstOf(alert.Annotations["description"], alert.ValueString),
+					maxArticleDescriptionLength),
+				Link:     strutil.FirstOf(alert.Link(), n.ExternalURL),
+				ImageUrl: alert.ImageURL,
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+		messages = append(messages, card)
+	}
+	return messages, nil
+}
+
+// status colors the text by alert status
+func status(status, text string) md.Segment {
+	if status == "resolved" {
+		return md.ColorGreen(text)
+	}
+	return md.ColorOrangeRed(text)
+}
+
+// values format the values as sorted key=value pairs
+func values(values map[string]float64) string {
+	pairs := make([]string, 0, len(values))
+	for k, v := range values {
+		pairs = append(pairs, fmt.Sprintf("%s=%g", k, v))
+	}
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, ", ")
+}
+
+// Option represents additional receiver configuration
+type Option func(*Receiver)
+
+// WithFormat sets the message format of alerts
+func WithFormat(format Format) Option {
+	return func(r *Receiver) {
+		r.format = format
+	}
+}
+
+// WithFallback sets the robot of receivers not in clients
+func WithFallback(client *workrobot.Client) Option {
+	return func(r *Receiver) {
+		r.fallback = client
+	}
+}
+
+// New create a receiver sends grafana alerts to clients by the contact point name
+func New(clients map[string]*workrobot.Client, options ...Option) *Receiver {
+	r := &Receiver{clients: clients, format: FormatMarkdown}
+	for _, opt := range options {
+		opt(r)
+	}
+	return r
+}
@@ -0,0 +1,124 @@
+package grafana
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/wjiec/workrobot"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func loadNotification(t *testing.T) *Notification {
+	bs, err := ioutil.ReadFile("testdata/webhook.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var n Notification
+	if err := json.Unmarshal(bs, &n); err != nil {
+		t.Fatal(err)
+	}
+	return &n
+}
+
+func TestReceiver_Render(t *testing.T) {
+	n := loadNotification(t)
+
+	messages, err := New(nil).Render(n)
+	if assert.NoError(t, err) && assert.Len(t, messages, 1) {
+		content := string(messages[0].Message())
+		assert.Contains(t, content, "[FIRING:1] HighCPU")
+		assert.Contains(t, content, "[Dashboard](https://grafana.example.com/d/dashboard)")
+		assert.Contains(t, content, "[Image](https://grafana.example.com/public/img/attachments/abc.png)")
+	}
+
+	for i := 0; i < 8; i++ {
+		alert := *n.Alerts[0]
+		alert.Annotations = map[string]string{"description": strings.Repeat("x", 2000)}
+		n.Alerts = append(n.Alerts, &alert)
+	}
+	messages, err = New(nil).Render(n)
+	if assert.NoError(t, err) && assert.True(t, len(messages) > 1) {
+		for _, msg := range messages {
+			assert.Contains(t, string(msg.Message()), "xxx...")
+		}
+	}
+
+	messages, err = New(nil, WithFormat(FormatCard)).Render(n)
+	if assert.NoError(t, err) && assert.Len(t, messages, 2) {
+		content := string(messages[0].Message())
+		assert.Contains(t, content, `"url":"https://grafana.example.com/d/dashboard?viewPanel=1"`)
+		assert.Contains(t, content, `"picurl":"https://grafana.example.com/public/img/attachments/abc.png"`)
+	}
+}
+
+func TestReceiver_RenderLarge(t *testing.T) {
+	n := loadNotification(t)
+	n.Title = strings.Repeat("t", 10000)
+	for i := 0; i < 3; i++ {
+		alert := *n.Alerts[0]
+		alert.Annotations = map[string]string{"summary": strings.Repeat("s", 10000), "description": strings.Repeat("d", 10000)}
+		alert.ValueString = strings.Repeat("v", 10000)
+		alert.DashboardURL = "https://grafana.example.com/d/" + strings.Repeat("x", 4096)
+		alert.PanelURL = "https://grafana.example.com/panel"
+		n.Alerts = append(n.Alerts, &alert)
+	}
+
+	messages, err := New(nil).Render(n)
+	if assert.NoError(t, err) {
+		for _, msg := range messages {
+			var data struct {
+				Markdown struct{ Content string }
+			}
+			_ = json.Unmarshal(msg.Message(), &data)
+			assert.LessOrEqual(t, len(data.Markdown.Content), workrobot.MarkdownMessageMaxLength)
+			// the long link is dropped instead of cut
+			assert.NotContains(t, data.Markdown.Content, "/d/xxx")
+			if strings.Contains(data.Markdown.Content, strings.Repeat("s", 100)) {
+				assert.Contains(t, data.Markdown.Content, "[Panel](https://grafana.example.com/panel)")
+			}
+		}
+	}
+
+	messages, err = New(nil, WithFormat(FormatCard)).Render(n)
+	if assert.NoError(t, err) {
+		var data struct {
+			Card struct{ Articles []*workrobot.Article }
+		}
+		_ = json.Unmarshal(messages[0].Message(), &data)
+		if assert.Len(t, data.Card.Articles, 4) {
+			assert.LessOrEqual(t, len(data.Card.Articles[3].Title), maxArticleTitleLength)
+			assert.LessOrEqual(t, len(data.Card.Articles[3].Description), maxArticleDescriptionLength)
+		}
+	}
+}
+
+func TestReceiver_ServeHTTP(t *testing.T) {
+	var received []string
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bs, _ := ioutil.ReadAll(r.Body)
+		received = append(received, string(bs))
+		_, _ = fmt.Fprint(w, `{"errcode":0,"errmsg":"ok"}`)
+	}))
+	defer gateway.Close()
+
+	client, _ := workrobot.NewClient("", workrobot.WithWebhook(gateway.URL))
+	server := httptest.NewServer(New(nil, WithFallback(client)))
+	defer server.Close()
+
+	f, _ := os.Open("testdata/webhook.json")
+	defer func() { _ = f.Close() }()
+
+	resp, err := http.Post(server.URL, "application/json", f)
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Len(t, received, 1)
+	}
+}
@@ -0,0 +1,239 @@
+package workrobot
+
+import (
+	"bytes"
+	goimage "image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// ImageFormat represents the format of image accepted by the gateway
+type ImageFormat string
+
+const (
+	// ImageJPEG represents the jpg image
+	ImageJPEG ImageFormat = "jpeg"
+	// ImagePNG represents the png image
+	ImagePNG ImageFormat = "png"
+)
+
+// DefaultJPEGQuality is the quality of jpeg encoded from image.Image
+const DefaultJPEGQuality = 90
+
+const (
+	// max size of image data accepted by compression
+	maxCompressFileSize = 16 * MaxImageFileSize
+	// max pixels of image decoded by compression, about 8K x 5K
+	maxCompressPixels = 40 * 1000 * 1000
+)
+
+// DetectImageFormat returns the format of image data by the magic bytes,
+// or empty when it is neither jpg nor png
+func DetectImageFormat(data []byte) ImageFormat {
+	switch {
+	case bytes.HasPrefix(data, []byte("\xff\xd8\xff")):
+		return ImageJPEG
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return ImagePNG
+	}
+	return ""
+}
+
+// Format returns the format of image
+func (img *Image) Format() ImageFormat {
+	return DetectImageFormat(img.data[:img.len])
+}
+
+// Compression returns how the image is compressed, or nil when the image
+// is sent as is
+func (img *Image) Compression() *Compression {
+	return img.compression
+}
+
+// fromCompressed read image data from reader, and compress it when the
+// image exceeds the limit
+func (img *Image) fromCompressed(reader io.Reader) error {
+	data, err := ioutil.ReadAll(io.LimitReader(reader, maxCompressFileSize+1))
+	if err != nil {
+		return errors.Wrap(err, "image unreadable")
+	}
+	if len(data) > maxCompressFileSize {
+		return ErrImageTooLarge
+	}
+	if len(data) < MaxImageFileSize {
+		return img.From(bytes.NewReader(data))
+	}
+
+	compressed, compression, err := CompressImage(data)
+	if err != nil {
+		return err
+	}
+	if err := img.From(bytes.NewReader(compressed)); err != nil {
+		return err
+	}
+
+	img.compression = compression
+	return nil
+}
+
+// ImageOption represents additional image configuration
+type ImageOption func(*imageOptions)
+
+// imageOptions represents the configuration of creating images
+type imageOptions struct {
+	compress bool
+}
+
+// WithImageCompression compresses images exceeding MaxImageFileSize by
+// CompressImage instead of returning ErrImageTooLarge
+func WithImageCompression() ImageOption {
+	return func(o *imageOptions) {
+		o.compress = true
+	}
+}
+
+// NewImageFromBytes create an image message from jpg or png data
+func NewImageFromBytes(data []byte, options ...ImageOption) (*Image, error) {
+	return NewImage(bytes.NewReader(data), options...)
+}
+
+// NewImageFromFile create an image message from jpg or png file
+func NewImageFromFile(filename string, options ...ImageOption) (*Image, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot open image")
+	}
+	defer func() { _ = f.Close() }()
+
+	return NewImage(f, options...)
+}
+
+// NewImageFromImage create an image message by encoding img in format, jpeg
+// is encoded in DefaultJPEGQuality
+func NewImageFromImage(img goimage.Image, format ImageFormat, options ...ImageOption) (*Image, error) {
+	var buf bytes.Buffer
+	switch format {
+	case ImageJPEG:
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: DefaultJPEGQuality}); err != nil {
+			return nil, errors.Wrap(err, "cannot encode jpeg")
+		}
+	case ImagePNG:
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, errors.Wrap(err, "cannot encode png")
+		}
+	default:
+		return nil, ErrUnsupportedImage
+	}
+
+	return NewImageFromBytes(buf.Bytes(), options...)
+}
+
+// jpeg qualities tried in order before downscaling
+var compressQualities = []int{DefaultJPEGQuality, 80, 70, 60, 50}
+
+// Compression represents the result of compressing an image
+type Compression struct {
+	OriginalSize int
+	Size         int
+	Quality      int
+	Width        int
+	Height       int
+}
+
+// CompressImage re-encodes the jpg or png data as jpeg in decreasing quality,
+// and then downscales the dimensions in the lowest quality until the image
+// fits in MaxImageFileSize, the transparent pixels are filled in white
+//
+// ErrImageTooLarge is returned without decoding when the data or the pixels
+// of image are too large to compress
+func CompressImage(data []byte) ([]byte, *Compression, error) {
+	if DetectImageFormat(data) == "" {
+		return nil, nil, ErrUnsupportedImage
+	}
+	if len(data) > maxCompressFileSize {
+		return nil, nil, ErrImageTooLarge
+	}
+
+	config, _, err := goimage.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "cannot decode image")
+	}
+	if int64(config.Width)*int64(config.Height) > maxCompressPixels {
+		return nil, nil, ErrImageTooLarge
+	}
+
+	src, _, err := goimage.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "cannot decode image")
+	}
+
+	flat := flatten(src)
+	img, qualities := flat, compressQualities
+	width, height := flat.Rect.Dx(), flat.Rect.Dy()
+	for width > 0 && height > 0 {
+		if width != flat.Rect.Dx() {
+			img = downscale(flat, width, height)
+		}
+
+		for _, quality := range qualities {
+			var buf bytes.Buffer
+			if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+				return nil, nil, errors.Wrap(err, "cannot encode jpeg")
+			}
+
+			if buf.Len() < MaxImageFileSize {
+				return buf.Bytes(), &Compression{OriginalSize: len(data), Size: buf.Len(),
+					Quality: quality, Width: width, Height: height}, nil
+			}
+		}
+
+		qualities = qualities[len(qualities)-1:]
+		width, height = width*3/4, height*3/4
+	}
+	return nil, nil, ErrImageTooLarge
+}
+
+// flatten draws the image on a white background
+func flatten(src goimage.Image) *goimage.RGBA {
+	bounds := src.Bounds()
+	dst := goimage.NewRGBA(goimage.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Rect, goimage.White, goimage.Point{}, draw.Src)
+	draw.Draw(dst, dst.Rect, src, bounds.Min, draw.Over)
+	return dst
+}
+
+// downscale resizes the image to the smaller dimensions by averaging the
+// source pixels covered by each pixel
+func downscale(src *goimage.RGBA, width, height int) *goimage.RGBA {
+	dst := goimage.NewRGBA(goimage.Rect(0, 0, width, height))
+	sw, sh := src.Rect.Dx(), src.Rect.Dy()
+	for y := 0; y < height; y++ {
+		y0, y1 := y*sh/height, (y+1)*sh/height
+		for x := 0; x < width; x++ {
+			x0, x1 := x*sw/width, (x+1)*sw/width
+
+			var sum [4]int
+			for sy := y0; sy < y1; sy++ {
+				i := src.PixOffset(x0, sy)
+				for sx := x0; sx < x1; sx, i = sx+1, i+4 {
+					sum[0] += int(src.Pix[i])
+					sum[1] += int(src.Pix[i+1])
+					sum[2] += int(src.Pix[i+2])
+					sum[3] += int(src.Pix[i+3])
+				}
+			}
+
+			n, j := (x1-x0)*(y1-y0), dst.PixOffset(x, y)
+			for c := 0; c < 4; c++ {
+				dst.Pix[j+c] = uint8(sum[c] / n)
+			}
+		}
+	}
+	return dst
+}
@@ -0,0 +1,159 @@
+package workrobot
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	goimage "image"
+	"image/color"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestImage() goimage.Image {
+	img := goimage.NewRGBA(goimage.Rect(0, 0, 16, 16))
+	for x := 0; x < 16; x++ {
+		img.Set(x, x, color.RGBA{R: 255, A: 255})
+	}
+	return img
+}
+
+func TestDetectImageFormat(t *testing.T) {
+	assert.Equal(t, ImageJPEG, DetectImageFormat([]byte("\xff\xd8\xff\xe0\x00\x10JFIF")))
+	assert.Equal(t, ImagePNG, DetectImageFormat([]byte("\x89PNG\r\n\x1a\n\x00")))
+	assert.Equal(t, ImageFormat(""), DetectImageFormat([]byte("GIF89a")))
+	assert.Equal(t, ImageFormat(""), DetectImageFormat(nil))
+}
+
+func TestNewImageFromImage(t *testing.T) {
+	for _, format := range []ImageFormat{ImageJPEG, ImagePNG} {
+		img, err := NewImageFromImage(newTestImage(), format)
+		if assert.NoError(t, err) {
+			assert.Equal(t, format, img.Format())
+			assert.Contains(t, string(img.Message()), `"msgtype":"image"`)
+		}
+	}
+
+	_, err := NewImageFromImage(newTestImage(), "gif")
+	assert.ErrorIs(t, err, ErrUnsupportedImage)
+}
+
+func TestNewImageFromBytes(t *testing.T) {
+	_, err := NewImageFromBytes([]byte("GIF89a"))
+	assert.ErrorIs(t, err, ErrUnsupportedImage)
+
+	_, err = NewImageFromBytes(nil)
+	assert.ErrorIs(t, err, ErrUnsupportedImage)
+}
+
+func TestNewImageFromFile(t *testing.T) {
+	img, _ := NewImageFromImage(newTestImage(), ImagePNG)
+	filename := filepath.Join(t.TempDir(), "test.png")
+	_ = ioutil.WriteFile(filename, img.data[:img.len], 0644)
+
+	loaded, err := NewImageFromFile(filename)
+	if assert.NoError(t, err) {
+		assert.Equal(t, img.Message(), loaded.Message())
+	}
+
+	_, err = NewImageFromFile(filepath.Join(t.TempDir(), "not-exists.png"))
+	assert.Error(t, err)
+}
+
+func newNoisyImage(width, height int) goimage.Image {
+	img := goimage.NewRGBA(goimage.Rect(0, 0, width, height))
+	rand.New(rand.NewSource(1)).Read(img.Pix)
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 255
+	}
+	return img
+}
+
+func TestCompressImage(t *testing.T) {
+	_, _, err := CompressImage([]byte("GIF89a"))
+	assert.ErrorIs(t, err, ErrUnsupportedImage)
+
+	_, _, err = CompressImage([]byte("\x89PNG\r\n\x1a\n\x00"))
+	assert.Error(t, err)
+
+	var buf bytes.Buffer
+	_ = png.Encode(&buf, newNoisyImage(2400, 2400))
+	original := buf.Bytes()
+
+	_, err = NewImageFromBytes(original)
+	assert.ErrorIs(t, err, ErrImageTooLarge)
+
+	img, err := NewImageFromBytes(original, WithImageCompression())
+	if assert.NoError(t, err) {
+		assert.Equal(t, ImageJPEG, img.Format())
+		if compression := img.Compression(); assert.NotNil(t, compression) {
+			assert.Equal(t, len(original), compression.OriginalSize)
+			assert.Equal(t, img.len, compression.Size)
+			assert.Less(t, compression.Size, MaxImageFileSize)
+			assert.Equal(t, compressQualities[len(compressQualities)-1], compression.Quality)
+			assert.Less(t, compression.Width, 2400)
+			assert.Equal(t, compression.Width, compression.Height)
+		}
+	}
+
+	small, _ := NewImageFromImage(newTestImage(), ImagePNG)
+	img, err = NewImageFromBytes(small.data[:small.len], WithImageCompression())
+	if assert.NoError(t, err) {
+		assert.Nil(t, img.Compression())
+		assert.Equal(t, small.Message(), img.Message())
+	}
+}
+
+func TestCompressImage_Limit(t *testing.T) {
+	var buf bytes.Buffer
+	_ = png.Encode(&buf, newTestImage())
+	data := buf.Bytes()
+
+	// declares 100000 x 100000 pixels in the header
+	binary.BigEndian.PutUint32(data[16:], 100000)
+	binary.BigEndian.PutUint32(data[20:], 100000)
+	binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(data[12:29]))
+
+	_, _, err := CompressImage(data)
+	assert.ErrorIs(t, err, ErrImageTooLarge)
+
+	huge := io.MultiReader(bytes.NewReader(data), io.LimitReader(zeroReader{}, maxCompressFileSize))
+	_, err = NewImage(huge, WithImageCompression())
+	assert.ErrorIs(t, err, ErrImageTooLarge)
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+func TestFlatten(t *testing.T) {
+	src := goimage.NewNRGBA(goimage.Rect(10, 10, 12, 12))
+	src.Set(10, 10, color.NRGBA{R: 255, A: 255})
+
+	dst := flatten(src)
+	assert.Equal(t, goimage.Rect(0, 0, 2, 2), dst.Rect)
+	assert.Equal(t, color.RGBA{R: 255, A: 255}, dst.RGBAAt(0, 0))
+	assert.Equal(t, color.RGBA{R: 255, G: 255, B: 255, A: 255}, dst.RGBAAt(1, 1))
+}
+
+func TestDownscale(t *testing.T) {
+	src := goimage.NewRGBA(goimage.Rect(0, 0, 4, 2))
+	src.SetRGBA(0, 0, color.RGBA{R: 200, A: 255})
+	src.SetRGBA(1, 1, color.RGBA{G: 100, A: 255})
+	src.SetRGBA(3, 0, color.RGBA{B: 40, A: 255})
+
+	dst := downscale(src, 2, 1)
+	assert.Equal(t, color.RGBA{R: 50, G: 25, A: 127}, dst.RGBAAt(0, 0))
+	assert.Equal(t, color.RGBA{B: 10, A: 63}, dst.RGBAAt(1, 0))
+}
@@ -0,0 +1,73 @@
+package workrobot
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// Call represents a single message delivery passing through the interceptors
+type Call struct {
+	// Message is the message to be sent, interceptors can replace it
+	// before calling next to rewrite the content
+	Message Messager
+	// Webhook is the webhook address the message sent to
+	Webhook string
+	// Attempt is the number of times the message has been sent to the gateway
+	Attempt int
+	// Dispatched is the time of the message first sent to the gateway
+	Dispatched time.Time
+	// Latency is the duration of the last gateway request
+	Latency time.Duration
+	// Receipt is the gateway response of the last attempt, nil when
+	// the request does not reach the gateway or response unreadable
+	Receipt *Receipt
+}
+
+// Type returns the type of the current message, e.g. text, markdown, image
+func (c *Call) Type() string {
+	return MessageType(c.Message)
+}
+
+// Payload returns the request payload of the current message
+func (c *Call) Payload() []byte {
+	return c.Message.Message()
+}
+
+// Handler represents the next step to deliver a message
+type Handler func(ctx context.Context, call *Call) error
+
+// Interceptor represents a hook wrapped around each message delivery, it should
+// call next to continue the delivery, or returns directly to skip it
+type Interceptor func(ctx context.Context, call *Call, next Handler) error
+
+// WithInterceptor append interceptors to the robot, the first registered
+// interceptor is the outermost one and runs first
+func WithInterceptor(interceptors ...Interceptor) ClientOption {
+	return func(client *Client) error {
+		client.interceptors = append(client.interceptors, interceptors...)
+		return nil
+	}
+}
+
+// chain wraps the handler with interceptors, the first interceptor is the outermost
+func chain(interceptors []Interceptor, handler Handler) Handler {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], handler
+		handler = func(ctx context.Context, call *Call) error {
+			return interceptor(ctx, call, next)
+		}
+	}
+	return handler
+}
+
+// MessageType returns the type of the message, e.g. text, markdown, image
+func MessageType(msg Messager) string {
+	var data struct {
+		MessageType string `json:"msgtype"`
+	}
+
+	// ignored error and returns empty type for unknown message
+	_ = json.Unmarshal(msg.Message(), &data)
+	return data.MessageType
+}
@@ -0,0 +1,81 @@
+package workrobot
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithInterceptor(t *testing.T) {
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bs, _ := ioutil.ReadAll(r.Body)
+		received = string(bs)
+		_, _ = fmt.Fprint(w, `{"errcode":0,"errmsg":"ok"}`)
+	}))
+	defer server.Close()
+
+	var steps []string
+	var call *Call
+	c, err := NewClient("", WithWebhook(server.URL), WithInterceptor(
+		func(ctx context.Context, cl *Call, next Handler) error {
+			steps = append(steps, "outer:"+cl.Type())
+			err := next(ctx, cl)
+			call = cl
+			return err
+		},
+		func(ctx context.Context, cl *Call, next Handler) error {
+			steps = append(steps, "inner")
+			cl.Message, _ = NewText("rewritten")
+			return next(ctx, cl)
+		},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, _ := NewMarkdown("original")
+	if assert.NoError(t, c.Send(msg)) {
+		assert.Equal(t, []string{"outer:markdown", "inner"}, steps)
+		assert.Contains(t, received, "rewritten")
+		assert.Equal(t, 1, call.Attempt)
+		assert.Equal(t, "text", call.Type())
+		assert.Equal(t, 0, call.Receipt.Code)
+	}
+}
+
+func TestWithInterceptor_Receipt(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `{"errcode":93000,"errmsg":"invalid webhook url"}`)
+	}))
+	defer server.Close()
+
+	var attempts int
+	c, _ := NewClient("", WithWebhook(server.URL), WithInterceptor(
+		func(ctx context.Context, call *Call, next Handler) error {
+			_ = next(ctx, call)
+			err := next(ctx, call)
+			attempts = call.Attempt
+			return err
+		},
+	))
+
+	msg, _ := NewText("hello")
+	err := c.Send(msg)
+
+	var receipt *Receipt
+	if assert.ErrorAs(t, err, &receipt) {
+		assert.Equal(t, 93000, receipt.Code)
+	}
+	assert.Equal(t, 2, attempts)
+}
+
+func TestMessageType(t *testing.T) {
+	assert.Equal(t, "text", MessageType(NewMention(nil, nil, true)))
+	assert.Equal(t, "file", MessageType(NewMedia("media-id")))
+}
@@ -0,0 +1,48 @@
+// Package strutil implements string helpers shared by the packages of workrobot.
+package strutil
+
+import "unicode/utf8"
+
+// ellipsis is appended to the truncated string
+const ellipsis = "..."
+
+// FirstOf returns the first non-empty value
+func FirstOf(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// Truncate returns s when it is at most n bytes, or the prefix of s with an
+// ellipsis at most n bytes in total, the runes of s are not broken
+func Truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	if n < len(ellipsis) {
+		return ellipsis[:n]
+	}
+
+	n -= len(ellipsis)
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + ellipsis
+}
+
+// TruncateRunes returns s when it has at most n runes, or the prefix of s with
+// an ellipsis at most n runes in total
+func TruncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	if n < len(ellipsis) {
+		return ellipsis[:n]
+	}
+
+	runes := []rune(s)
+	return string(runes[:n-len(ellipsis)]) + ellipsis
+}
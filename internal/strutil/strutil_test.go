@@ -0,0 +1,31 @@
+package strutil
+
+import (
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFirstOf(t *testing.T) {
+	assert.Equal(t, "b", FirstOf("", "b", "c"))
+	assert.Equal(t, "", FirstOf("", ""))
+	assert.Equal(t, "", FirstOf())
+}
+
+func TestTruncate(t *testing.T) {
+	assert.Equal(t, "hello", Truncate("hello", 5))
+	assert.Equal(t, "he...", Truncate("hello world", 5))
+	assert.Equal(t, "..", Truncate("hello", 2))
+
+	s := Truncate("中文中文", 8)
+	assert.Equal(t, "中...", s)
+	assert.True(t, utf8.ValidString(s))
+	assert.LessOrEqual(t, len(s), 8)
+}
+
+func TestTruncateRunes(t *testing.T) {
+	assert.Equal(t, "中文中文", TruncateRunes("中文中文", 4))
+	assert.Equal(t, "中文...", TruncateRunes("中文中文中文", 5))
+	assert.Equal(t, "..", TruncateRunes("hello", 2))
+}
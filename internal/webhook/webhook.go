@@ -0,0 +1,71 @@
+// Package webhook implements the request handling shared by the alert receivers.
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/wjiec/workrobot"
+
+	"github.com/pkg/errors"
+)
+
+// max size of webhook request body
+const maxRequestSize = 4 * 1024 * 1024
+
+// StatusError represents an error replied with the http status
+type StatusError struct {
+	Status int
+	Err    error
+}
+
+// Error implements error
+func (e *StatusError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *StatusError) Unwrap() error {
+	return e.Err
+}
+
+// WithStatus returns the error replied with the status
+func WithStatus(status int, err error) error {
+	return &StatusError{Status: status, Err: err}
+}
+
+// Render returns the robot and the messages of the decoded payload
+type Render func() (*workrobot.Client, []workrobot.Messager, error)
+
+// Serve accepts the POST request only, decodes the json body into payload and
+// sends the messages from render to the robot, errors of render are replied
+// with 500 unless it is a StatusError, and errors of sending with 502
+func Serve(w http.ResponseWriter, req *http.Request, payload interface{}, render Render) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := json.NewDecoder(http.MaxBytesReader(w, req.Body, maxRequestSize)).Decode(payload); err != nil {
+		http.Error(w, "invalid webhook payload: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	client, messages, err := render()
+	if err != nil {
+		status := http.StatusInternalServerError
+		var se *StatusError
+		if errors.As(err, &se) {
+			status = se.Status
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	if err := client.SendContext(req.Context(), messages...); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,45 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/wjiec/workrobot"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServe(t *testing.T) {
+	var received int
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received++
+		_, _ = fmt.Fprint(w, `{"errcode":0,"errmsg":"ok"}`)
+	}))
+	defer gateway.Close()
+
+	client, _ := workrobot.NewClient("", workrobot.WithWebhook(gateway.URL))
+	text, _ := workrobot.NewText("hello")
+
+	serve := func(method, body string, err error) int {
+		var payload struct{ Name string }
+		rec := httptest.NewRecorder()
+		Serve(rec, httptest.NewRequest(method, "/", strings.NewReader(body)), &payload,
+			func() (*workrobot.Client, []workrobot.Messager, error) {
+				return client, []workrobot.Messager{text}, err
+			})
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusMethodNotAllowed, serve(http.MethodGet, "", nil))
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "{", nil))
+	assert.Equal(t, http.StatusInternalServerError, serve(http.MethodPost, "{}", errors.New("render")))
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodPost, "{}", WithStatus(http.StatusNotFound, errors.New("unknown"))))
+	assert.Equal(t, 0, received)
+
+	assert.Equal(t, http.StatusOK, serve(http.MethodPost, `{"name":"alert"}`, nil))
+	assert.Equal(t, 1, received)
+}
@@ -0,0 +1,285 @@
+// Package job runs commands and reports the results to robot, like the exit
+// code, duration and the tail of output, the full output is attached as a file
+// when it is too long to fit in the message.
+package job
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wjiec/workrobot"
+	"github.com/wjiec/workrobot/internal/strutil"
+	md "github.com/wjiec/workrobot/markdown"
+
+	"github.com/pkg/errors"
+)
+
+// max size of captured output, the earlier output is discarded
+const maxOutputSize = 16 * 1024 * 1024
+
+// max length of the title, command and error in message
+const (
+	maxTitleLength   = 128
+	maxCommandLength = 512
+	maxErrorLength   = 512
+)
+
+// ExitNotStarted is the exit code of commands cannot be started
+const ExitNotStarted = 127
+
+// Notify represents when the result is sent
+type Notify int
+
+const (
+	// NotifyAlways sends results of all runs
+	NotifyAlways Notify = iota
+	// NotifyFailure sends results of failed runs
+	NotifyFailure
+	// NotifyChange sends results when the state changed from the previous run,
+	// the first run is treated as changed when it failed
+	NotifyChange
+)
+
+// Result represents the result of a command run
+type Result struct {
+	Command  []string
+	ExitCode int
+	Err      error
+	Started  time.Time
+	Duration time.Duration
+	Output   []byte
+}
+
+// Success checks whether the command exited with 0
+func (r *Result) Success() bool {
+	return r.Err == nil && r.ExitCode == 0
+}
+
+// Markdown renders the result as markdown with the tail of output, and
+// truncated reports whether the output is not fully contained
+func (r *Result) Markdown(title string, lines int) (msg *workrobot.Markdown, truncated bool, err error) {
+	status := md.ColorGreen("succeeded")
+	if !r.Success() {
+		status = md.ColorOrangeRed("failed")
+	}
+
+	if title == "" && len(r.Command) != 0 {
+		title = r.Command[0]
+	}
+	header := md.Join(" ", md.Bold(strutil.Truncate(strutil.FirstOf(title, "command"), maxTitleLength)), status)
+	summary := fmt.Sprintf("exit code %d in %s", r.ExitCode, r.Duration.Round(time.Millisecond))
+	if r.Err != nil {
+		summary += ": " + strutil.Truncate(r.Err.Error(), maxErrorLength)
+	}
+	command := strutil.Truncate(strings.Join(r.Command, " "), maxCommandLength)
+	content := []string{header.String(), md.Quote("command: " + command).String(), md.Quote(summary).String()}
+
+	output := strings.Split(strings.TrimRight(string(r.Output), "\n"), "\n")
+	if len(output) == 1 && output[0] == "" {
+		output = nil
+	}
+	if len(output) > lines {
+		output, truncated = output[len(output)-lines:], true
+	}
+
+	size := len(strings.Join(content, "\n"))
+	var tail []string
+	for i := len(output) - 1; i >= 0; i-- {
+		line := md.ColorGray(strings.ToValidUTF8(output[i], "")).String()
+		if size += len(line) + 1; size > workrobot.MarkdownMessageMaxLength {
+			truncated = true
+			break
+		}
+		tail = append([]string{line}, tail...)
+	}
+
+	var markdown workrobot.Markdown
+	if err := markdown.RawContent(strings.Join(append(content, tail...), "\n")); err != nil {
+		return nil, truncated, err
+	}
+	return &markdown, truncated, nil
+}
+
+// tailBuffer represents a writer keeps the last n bytes
+type tailBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+	max int
+}
+
+// Write implements io.Writer
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.max {
+		b.buf = append(b.buf[:0], b.buf[len(b.buf)-b.max:]...)
+	}
+	return len(p), nil
+}
+
+// Runner represents a runner runs commands and reports the results
+type Runner struct {
+	client    *workrobot.Client
+	title     string
+	notify    Notify
+	stateFile string
+	lines     int
+	stdin     io.Reader
+	stdout    io.Writer
+	stderr    io.Writer
+}
+
+// Run runs the command and sends the result by the notify mode, the
+// returned error is the send error, the run error is in the result
+func (r *Runner) Run(ctx context.Context, name string, args ...string) (*Result, error) {
+	output := &tailBuffer{max: maxOutputSize}
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdin = r.stdin
+	cmd.Stdout, cmd.Stderr = io.MultiWriter(output, r.stdout), io.MultiWriter(output, r.stderr)
+	if r.stdout == r.stderr { // keeps the order of output in the same pipe
+		cmd.Stderr = cmd.Stdout
+	}
+
+	result := &Result{Command: append([]string{name}, args...), Started: time.Now()}
+	err := cmd.Run()
+	result.Duration = time.Since(result.Started)
+	result.Output = output.buf
+
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitCode()
+	case err != nil:
+		result.ExitCode, result.Err = ExitNotStarted, err
+	}
+
+	notify, err := r.shouldNotify(result)
+	if err != nil {
+		return result, err
+	}
+	if notify {
+		if err := r.Send(ctx, result); err != nil {
+			return result, err
+		}
+	}
+	return result, r.saveState(result)
+}
+
+// state returns the state of result saved for NotifyChange
+func state(result *Result) string {
+	if result.Success() {
+		return "success"
+	}
+	return "failure"
+}
+
+// shouldNotify checks whether the result should be sent by the notify mode
+func (r *Runner) shouldNotify(result *Result) (bool, error) {
+	switch r.notify {
+	case NotifyFailure:
+		return !result.Success(), nil
+	case NotifyChange:
+		if r.stateFile == "" {
+			return !result.Success(), nil
+		}
+
+		previous, err := ioutil.ReadFile(r.stateFile)
+		if err != nil && !os.IsNotExist(err) {
+			return false, err
+		}
+
+		if len(previous) == 0 {
+			return !result.Success(), nil
+		}
+		return string(previous) != state(result), nil
+	}
+	return true, nil
+}
+
+// saveState saves the state of result for NotifyChange, it is called after
+// the result sent, so the change is notified again when sending failed
+func (r *Runner) saveState(result *Result) error {
+	if r.notify != NotifyChange || r.stateFile == "" {
+		return nil
+	}
+	return ioutil.WriteFile(r.stateFile, []byte(state(result)), 0644)
+}
+
+// Send sends the result to robot, the full output is uploaded as a file
+// when it is truncated in the message
+func (r *Runner) Send(ctx context.Context, result *Result) error {
+	msg, truncated, err := result.Markdown(r.title, r.lines)
+	if err != nil {
+		return err
+	}
+	if err := r.client.SendContext(ctx, msg); err != nil {
+		return err
+	}
+	if !truncated {
+		return nil
+	}
+
+	filename := fmt.Sprintf("%s-%s.log", strutil.FirstOf(r.title, "output"), result.Started.Format("20060102-150405"))
+	media, err := r.client.Uploader().UploadFromBytesContext(ctx, filename, result.Output)
+	if err != nil {
+		return err
+	}
+	return r.client.SendContext(ctx, workrobot.NewMedia(media.Id))
+}
+
+// Option represents additional runner configuration
+type Option func(*Runner)
+
+// WithTitle sets the title of results, like the name of job, defaults to the command name
+func WithTitle(title string) Option {
+	return func(r *Runner) {
+		r.title = title
+	}
+}
+
+// WithNotify sets when the result is sent, defaults to NotifyAlways, and the
+// state of NotifyChange is saved in the state file
+func WithNotify(notify Notify, stateFile string) Option {
+	return func(r *Runner) {
+		r.notify, r.stateFile = notify, stateFile
+	}
+}
+
+// WithTailLines sets the max lines of output in message, defaults to 20
+func WithTailLines(lines int) Option {
+	return func(r *Runner) {
+		r.lines = lines
+	}
+}
+
+// WithOutput copies the stdout and stderr of command to writers
+func WithOutput(stdout, stderr io.Writer) Option {
+	return func(r *Runner) {
+		r.stdout, r.stderr = stdout, stderr
+	}
+}
+
+// WithInput sets the stdin of command
+func WithInput(stdin io.Reader) Option {
+	return func(r *Runner) {
+		r.stdin = stdin
+	}
+}
+
+// New create a runner reports results to client
+func New(client *workrobot.Client, options ...Option) *Runner {
+	r := &Runner{client: client, notify: NotifyAlways, lines: 20, stdout: ioutil.Discard, stderr: ioutil.Discard}
+	for _, opt := range options {
+		opt(r)
+	}
+	return r
+}
@@ -0,0 +1,126 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wjiec/workrobot"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestClient(t *testing.T) (*workrobot.Client, *[]string) {
+	var received []string
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "upload_media") {
+			_, _ = fmt.Fprint(w, `{"errcode":0,"errmsg":"ok","type":"file","media_id":"media-1","created_at":"1380000000"}`)
+			return
+		}
+
+		bs, _ := ioutil.ReadAll(r.Body)
+		received = append(received, string(bs))
+		_, _ = fmt.Fprint(w, `{"errcode":0,"errmsg":"ok"}`)
+	}))
+	t.Cleanup(gateway.Close)
+
+	client, _ := workrobot.NewClient("key", workrobot.WithWebhook(gateway.URL+"/send"),
+		workrobot.WithUploadGateway(gateway.URL+"/upload_media"))
+	return client, &received
+}
+
+func TestRunner_Run(t *testing.T) {
+	client, received := newTestClient(t)
+
+	result, err := New(client, WithTitle("backup")).Run(context.Background(), "sh", "-c", "echo hello; echo oops >&2; exit 3")
+	if assert.NoError(t, err) {
+		assert.False(t, result.Success())
+		assert.Equal(t, 3, result.ExitCode)
+		assert.Equal(t, "hello\noops\n", string(result.Output))
+	}
+	if assert.Len(t, *received, 1) {
+		assert.Contains(t, (*received)[0], "**backup**")
+		assert.Contains(t, (*received)[0], "exit code 3 in")
+		assert.Contains(t, (*received)[0], "oops")
+	}
+
+	result, err = New(client).Run(context.Background(), "/nonexistent/command")
+	if assert.NoError(t, err) {
+		assert.Equal(t, ExitNotStarted, result.ExitCode)
+		assert.Error(t, result.Err)
+	}
+}
+
+func TestRunner_Notify(t *testing.T) {
+	client, received := newTestClient(t)
+
+	runner := New(client, WithNotify(NotifyFailure, ""))
+	_, _ = runner.Run(context.Background(), "true")
+	assert.Len(t, *received, 0)
+	_, _ = runner.Run(context.Background(), "false")
+	assert.Len(t, *received, 1)
+
+	state := filepath.Join(t.TempDir(), "state")
+	runner = New(client, WithNotify(NotifyChange, state))
+	_, _ = runner.Run(context.Background(), "true") // first success
+	assert.Len(t, *received, 1)
+	_, _ = runner.Run(context.Background(), "false") // changed
+	assert.Len(t, *received, 2)
+	_, _ = runner.Run(context.Background(), "false") // unchanged
+	assert.Len(t, *received, 2)
+	_, _ = runner.Run(context.Background(), "true") // recovered
+	assert.Len(t, *received, 3)
+
+	rejected := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `{"errcode":45009,"errmsg":"api freq out of limit"}`)
+	}))
+	defer rejected.Close()
+
+	broken, _ := workrobot.NewClient("key", workrobot.WithWebhook(rejected.URL+"/send"))
+	_, err := New(broken, WithNotify(NotifyChange, state)).Run(context.Background(), "false")
+	assert.Error(t, err)
+	_, err = runner.Run(context.Background(), "false") // still changed
+	assert.NoError(t, err)
+	assert.Len(t, *received, 4)
+}
+
+func TestRunner_Send(t *testing.T) {
+	client, received := newTestClient(t)
+
+	result, err := New(client, WithTailLines(5)).Run(context.Background(), "sh", "-c", "seq 1 100")
+	if assert.NoError(t, err) && assert.Len(t, *received, 2) {
+		assert.Contains(t, (*received)[0], "100")
+		assert.NotContains(t, (*received)[0], "95\\u003c")
+		assert.Contains(t, (*received)[1], `"media_id":"media-1"`)
+		assert.True(t, result.Success())
+	}
+}
+
+func TestResult_Markdown(t *testing.T) {
+	msg, _, err := (&Result{}).Markdown("", 5)
+	if assert.NoError(t, err) {
+		assert.Contains(t, string(msg.Message()), "**command**")
+	}
+
+	result := &Result{
+		Command:  []string{"echo", strings.Repeat("x", 8192)},
+		ExitCode: ExitNotStarted,
+		Err:      errors.New(strings.Repeat("e", 8192)),
+		Output:   []byte(strings.Repeat("output\n", 1000)),
+	}
+	msg, truncated, err := result.Markdown("", 1000)
+	if assert.NoError(t, err) {
+		content := string(msg.Message())
+		assert.True(t, truncated)
+		assert.Contains(t, content, "**echo**")
+		assert.Contains(t, content, "xxx...")
+		assert.Contains(t, content, "eee...")
+		assert.Contains(t, content, "output")
+	}
+}
@@ -0,0 +1,41 @@
+package workrobot
+
+import "context"
+
+// well-known label names of message
+const (
+	LabelSeverity    = "severity"
+	LabelService     = "service"
+	LabelTeam        = "team"
+	LabelEnvironment = "environment"
+)
+
+// Labels represents the metadata of message, e.g. severity, service, team
+type Labels map[string]string
+
+// Merge returns a copy of labels overridden by others
+func (l Labels) Merge(others Labels) Labels {
+	merged := make(Labels, len(l)+len(others))
+	for k, v := range l {
+		merged[k] = v
+	}
+	for k, v := range others {
+		merged[k] = v
+	}
+	return merged
+}
+
+// labelsContext represents the context key of message labels
+type labelsContext struct{}
+
+// WithLabels returns a context with the message labels merged into
+// the labels of parent context
+func WithLabels(ctx context.Context, labels Labels) context.Context {
+	return context.WithValue(ctx, labelsContext{}, LabelsFromContext(ctx).Merge(labels))
+}
+
+// LabelsFromContext returns the message labels of context, or nil if not set
+func LabelsFromContext(ctx context.Context) Labels {
+	labels, _ := ctx.Value(labelsContext{}).(Labels)
+	return labels
+}
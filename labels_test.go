@@ -0,0 +1,17 @@
+package workrobot
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithLabels(t *testing.T) {
+	assert.Nil(t, LabelsFromContext(context.Background()))
+
+	ctx := WithLabels(context.Background(), Labels{LabelService: "api", LabelSeverity: "info"})
+	ctx = WithLabels(ctx, Labels{LabelSeverity: "critical"})
+
+	assert.Equal(t, Labels{LabelService: "api", LabelSeverity: "critical"}, LabelsFromContext(ctx))
+}
@@ -0,0 +1,303 @@
+// Package logging forwards high-severity log records to a robot as markdown,
+// records are rate limited and deduplicated so a log storm will not exhaust
+// the quota of robot.
+//
+// The Handler implements slog.Handler, and adapters of zap and logrus are in
+// the subpackages zaprobot and logrusrobot.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wjiec/workrobot"
+	"github.com/wjiec/workrobot/internal/strutil"
+	md "github.com/wjiec/workrobot/markdown"
+
+	"github.com/pkg/errors"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// max length of log message in markdown
+	maxMessageLength = 1024
+	// max length of attribute value in markdown
+	maxValueLength = 512
+)
+
+// Field represents an attribute of log record
+type Field struct {
+	Key   string
+	Value string
+}
+
+// Record represents a log record will be sent
+type Record struct {
+	Time    time.Time
+	Level   string
+	Message string
+	Source  string
+	Fields  []Field
+}
+
+// key returns the dedup key of record, the fields are ignored
+func (r *Record) key() string {
+	return r.Level + "\x00" + r.Message
+}
+
+// Markdown renders the record as markdown, the message and fields are truncated
+// when the content is too long
+func (r *Record) Markdown(title string, suppressed int) (*workrobot.Markdown, error) {
+	message := strutil.Truncate(r.Message, maxMessageLength)
+	header := md.Join(" ", md.Bold(md.ColorOrangeRed(strings.ToUpper(r.Level))), message)
+	if title != "" {
+		header = md.Join(" ", md.Bold("["+title+"]"), header)
+	}
+	lines := []interface{}{header}
+
+	footer := []interface{}{r.Time.Format("2006-01-02 15:04:05.000")}
+	if r.Source != "" {
+		footer = append(footer, r.Source)
+	}
+	if suppressed != 0 {
+		footer = append(footer, fmt.Sprintf("%d records suppressed", suppressed))
+	}
+	tail := md.ColorGray(md.Join(" ", footer...))
+
+	// each line is counted with its newline by the markdown
+	size := len(header.String()) + len(tail.String()) + 2
+	for _, field := range r.Fields {
+		line := md.Quote(field.Key + ": " + strutil.Truncate(field.Value, maxValueLength))
+		if size += len(line.String()) + 1; size > workrobot.MarkdownMessageMaxLength {
+			break
+		}
+		lines = append(lines, line)
+	}
+	return workrobot.NewMarkdown(append(lines, tail)...)
+}
+
+// entry represents a queued record, the flushed is closed when all records
+// queued before it are sent
+type entry struct {
+	record     *Record
+	suppressed int
+	flushed    chan struct{}
+}
+
+// Sink represents the destination of log records, records are sent by a
+// background worker so logging is never blocked by the robot
+type Sink struct {
+	client       *workrobot.Client
+	title        string
+	limiter      *rate.Limiter
+	window       time.Duration
+	timeout      time.Duration
+	queueSize    int
+	errorHandler func(error)
+	now          func() time.Time
+
+	queue chan *entry
+	stop  chan struct{}
+	done  chan struct{}
+
+	mu         sync.Mutex
+	closed     bool
+	seen       map[string]time.Time
+	suppressed int
+}
+
+// Send queues the record to be sent to robot, it is dropped when rate limited,
+// the queue is full or the same level and message sent within the dedup window
+//
+// the ctx is not used to send the record since it is sent in background
+func (s *Sink) Send(_ context.Context, r *Record) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if last, found := s.seen[r.key()]; s.closed || (found && now.Sub(last) < s.window) {
+		s.suppressed++
+		return
+	}
+	if !s.limiter.AllowN(now, 1) {
+		s.suppressed++
+		return
+	}
+
+	select {
+	case s.queue <- &entry{record: r, suppressed: s.suppressed}:
+		s.suppressed = 0
+	default:
+		s.suppressed++
+		return
+	}
+
+	s.seen[r.key()] = now
+	for key, last := range s.seen {
+		if now.Sub(last) >= s.window {
+			delete(s.seen, key)
+		}
+	}
+}
+
+// Flush waits until the records queued before are sent or the ctx is done
+func (s *Sink) Flush(ctx context.Context) error {
+	flushed := make(chan struct{})
+	select {
+	case s.queue <- &entry{flushed: flushed}:
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-flushed:
+	case <-s.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return nil
+}
+
+// Close stops accepting records and waits until the queued records are sent,
+// each record is sent within the timeout
+func (s *Sink) Close() error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.stop)
+	}
+	s.mu.Unlock()
+
+	<-s.done
+	return nil
+}
+
+// run sends the queued records until the sink closed, the remaining
+// records are sent before exiting
+func (s *Sink) run() {
+	defer close(s.done)
+
+	for {
+		select {
+		case e := <-s.queue:
+			s.handle(e)
+		case <-s.stop:
+			for {
+				select {
+				case e := <-s.queue:
+					s.handle(e)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// handle sends the record of entry, or notifies the flush
+func (s *Sink) handle(e *entry) {
+	if e.flushed != nil {
+		close(e.flushed)
+		return
+	}
+
+	if err := s.send(e.record, e.suppressed); err != nil {
+		s.errorHandler(errors.Wrap(err, "cannot send log record"))
+	}
+}
+
+// send sends the record to robot within the timeout
+func (s *Sink) send(r *Record, suppressed int) error {
+	msg, err := r.Markdown(s.title, suppressed)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	return s.client.SendContext(ctx, msg)
+}
+
+// Option represents additional sink configuration
+type Option func(*Sink)
+
+// WithTitle sets the title of messages, like the service name
+func WithTitle(title string) Option {
+	return func(s *Sink) {
+		s.title = title
+	}
+}
+
+// WithRateLimit sends at most count records per duration, exceeded records are
+// dropped and counted in the next message, defaults to 10 per minute
+func WithRateLimit(count int, per time.Duration) Option {
+	return func(s *Sink) {
+		if count > 0 && per > 0 {
+			s.limiter = rate.NewLimiter(rate.Every(per/time.Duration(count)), count)
+		}
+	}
+}
+
+// WithDedupWindow drops records with the same level and message within
+// the window, defaults to 1 minute
+func WithDedupWindow(window time.Duration) Option {
+	return func(s *Sink) {
+		s.window = window
+	}
+}
+
+// WithTimeout sets the timeout of sending a record, defaults to 5 seconds
+func WithTimeout(timeout time.Duration) Option {
+	return func(s *Sink) {
+		s.timeout = timeout
+	}
+}
+
+// WithQueueSize sets the max number of records waiting to be sent, records
+// exceeded are dropped and counted in the next message, defaults to 64
+func WithQueueSize(size int) Option {
+	return func(s *Sink) {
+		if size > 0 {
+			s.queueSize = size
+		}
+	}
+}
+
+// WithErrorHandler sets the handler of send errors, errors are ignored by default,
+// the handler is called on the background worker
+func WithErrorHandler(handler func(error)) Option {
+	return func(s *Sink) {
+		s.errorHandler = handler
+	}
+}
+
+// NewSink create a sink sends log records to the robot in background, the
+// Close should be called before exiting to send the queued records
+func NewSink(client *workrobot.Client, options ...Option) *Sink {
+	s := &Sink{
+		client:       client,
+		limiter:      rate.NewLimiter(rate.Every(6*time.Second), 10),
+		window:       time.Minute,
+		timeout:      5 * time.Second,
+		queueSize:    64,
+		errorHandler: func(error) {},
+		now:          time.Now,
+		seen:         make(map[string]time.Time),
+	}
+	for _, opt := range options {
+		opt(s)
+	}
+
+	s.queue = make(chan *entry, s.queueSize)
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go s.run()
+
+	return s
+}
@@ -0,0 +1,199 @@
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wjiec/workrobot"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newGateway create a gateway records the received messages
+func newGateway(t *testing.T) (*workrobot.Client, func() []string) {
+	var mu sync.Mutex
+	var received []string
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bs, _ := ioutil.ReadAll(r.Body)
+
+		mu.Lock()
+		received = append(received, string(bs))
+		mu.Unlock()
+		_, _ = fmt.Fprint(w, `{"errcode":0,"errmsg":"ok"}`)
+	}))
+	t.Cleanup(gateway.Close)
+
+	client, _ := workrobot.NewClient("", workrobot.WithWebhook(gateway.URL))
+	return client, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), received...)
+	}
+}
+
+func TestRecord_Markdown(t *testing.T) {
+	r := &Record{
+		Time:    time.Date(2021, 6, 1, 8, 0, 0, 0, time.UTC),
+		Level:   "error",
+		Message: "connection refused",
+		Source:  "main.go:42",
+		Fields:  []Field{{Key: "host", Value: "db-1"}, {Key: "body", Value: strings.Repeat("x", 1024)}},
+	}
+
+	msg, err := r.Markdown("api", 3)
+	if assert.NoError(t, err) {
+		var payload struct {
+			Markdown struct {
+				Content string `json:"content"`
+			} `json:"markdown"`
+		}
+		_ = json.Unmarshal(msg.Message(), &payload)
+
+		content := payload.Markdown.Content
+		assert.Contains(t, content, `**[api]** **<font color="warning">ERROR</font>** connection refused`)
+		assert.Contains(t, content, `> host: db-1`)
+		assert.Contains(t, content, "2021-06-01 08:00:00.000 main.go:42 3 records suppressed")
+		assert.NotContains(t, content, strings.Repeat("x", 513))
+	}
+
+	for i := 0; i < 100; i++ {
+		r.Fields = append(r.Fields, Field{Key: "key", Value: strings.Repeat("y", 100)})
+	}
+	_, err = r.Markdown("", 0)
+	assert.NoError(t, err)
+
+	r.Message, r.Fields = strings.Repeat("z", workrobot.MarkdownMessageMaxLength), nil
+	msg, err = r.Markdown("api", 0)
+	if assert.NoError(t, err) {
+		assert.NotContains(t, string(msg.Message()), strings.Repeat("z", 1025))
+	}
+}
+
+func TestRecord_MarkdownBoundary(t *testing.T) {
+	var exact bool
+	for n := 400; n <= 500; n++ {
+		r := &Record{Time: time.Date(2021, 6, 1, 8, 0, 0, 0, time.UTC), Level: "error", Message: "failed"}
+		for i := 0; i < 7; i++ {
+			r.Fields = append(r.Fields, Field{Key: "key", Value: strings.Repeat("x", 500)})
+		}
+		r.Fields = append(r.Fields, Field{Key: "key", Value: strings.Repeat("y", n)})
+
+		msg, err := r.Markdown("", 0)
+		if !assert.NoError(t, err, "last field of %d bytes", n) {
+			continue
+		}
+
+		var payload struct {
+			Markdown struct {
+				Content string `json:"content"`
+			} `json:"markdown"`
+		}
+		_ = json.Unmarshal(msg.Message(), &payload)
+		assert.True(t, len(payload.Markdown.Content) < workrobot.MarkdownMessageMaxLength)
+		exact = exact || len(payload.Markdown.Content) == workrobot.MarkdownMessageMaxLength-1
+	}
+	assert.True(t, exact, "boundary not reached")
+}
+
+func TestSink_Send(t *testing.T) {
+	client, received := newGateway(t)
+
+	now := time.Date(2021, 6, 1, 8, 0, 0, 0, time.UTC)
+	sink := NewSink(client, WithRateLimit(2, time.Minute), WithDedupWindow(time.Minute))
+	sink.now = func() time.Time { return now }
+
+	send := func(message string) {
+		sink.Send(context.Background(), &Record{Time: now, Level: "error", Message: message})
+	}
+
+	send("a")
+	send("a") // deduplicated
+	send("b") // 1 record suppressed
+	send("c") // rate limited
+	assert.NoError(t, sink.Flush(context.Background()))
+	assert.Len(t, received(), 2)
+
+	now = now.Add(time.Minute)
+	send("a")
+	assert.NoError(t, sink.Flush(context.Background()))
+	if assert.Len(t, received(), 3) {
+		assert.Contains(t, received()[2], "1 records suppressed")
+	}
+
+	assert.NoError(t, sink.Close())
+	send("closed")
+	assert.Len(t, received(), 3)
+}
+
+func TestSink_Queue(t *testing.T) {
+	var mu sync.Mutex
+	var received []string
+	release := make(chan struct{})
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		bs, _ := ioutil.ReadAll(r.Body)
+
+		mu.Lock()
+		received = append(received, string(bs))
+		mu.Unlock()
+		_, _ = fmt.Fprint(w, `{"errcode":0,"errmsg":"ok"}`)
+	}))
+	defer gateway.Close()
+
+	client, _ := workrobot.NewClient("", workrobot.WithWebhook(gateway.URL))
+	sink := NewSink(client, WithQueueSize(1), WithRateLimit(100, time.Minute))
+
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		sink.Send(context.Background(), &Record{Time: start, Level: "error", Message: fmt.Sprint(i)})
+	}
+	assert.True(t, time.Since(start) < time.Second, "send should not block on robot")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, sink.Flush(ctx))
+
+	close(release)
+	assert.NoError(t, sink.Flush(context.Background()))
+	sink.Send(context.Background(), &Record{Time: start, Level: "error", Message: "last"})
+	assert.NoError(t, sink.Close())
+
+	mu.Lock()
+	defer mu.Unlock()
+	// the first record is sending, the second is queued, others are dropped
+	// until the queue is available
+	if assert.True(t, len(received) >= 2 && len(received) <= 3) {
+		assert.Contains(t, received[len(received)-1], "last")
+		assert.Contains(t, strings.Join(received, "\n"), "records suppressed")
+	}
+}
+
+func TestSink_Timeout(t *testing.T) {
+	blocked := make(chan struct{})
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-blocked
+	}))
+	defer gateway.Close()
+	defer close(blocked)
+
+	errs := make(chan error, 1)
+	client, _ := workrobot.NewClient("", workrobot.WithWebhook(gateway.URL))
+	sink := NewSink(client, WithTimeout(10*time.Millisecond), WithErrorHandler(func(err error) { errs <- err }))
+
+	sink.Send(context.Background(), &Record{Time: time.Now(), Level: "error", Message: "timeout"})
+	assert.NoError(t, sink.Close())
+	select {
+	case err := <-errs:
+		assert.Contains(t, err.Error(), "cannot send log record")
+	default:
+		t.Fatal("timeout error expected")
+	}
+}
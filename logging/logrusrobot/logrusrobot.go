@@ -0,0 +1,77 @@
+// Package logrusrobot implements a logrus.Hook sends log entries to robot.
+//
+//	logger.AddHook(logrusrobot.New(sink, logrus.ErrorLevel))
+package logrusrobot
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/wjiec/workrobot/logging"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Hook represents a logrus.Hook sends entries to sink
+type Hook struct {
+	sink   *logging.Sink
+	levels []logrus.Level
+}
+
+// Levels implements logrus.Hook
+func (h *Hook) Levels() []logrus.Level {
+	return h.levels
+}
+
+// Fire implements logrus.Hook, entries are sent in background and errors are
+// reported to the error handler of sink, panic entries are flushed before returning
+func (h *Hook) Fire(entry *logrus.Entry) error {
+	record := &logging.Record{Time: entry.Time, Level: entry.Level.String(), Message: entry.Message}
+	if entry.HasCaller() {
+		record.Source = fmt.Sprintf("%s:%d", filepath.Base(entry.Caller.File), entry.Caller.Line)
+	}
+
+	keys := make([]string, 0, len(entry.Data))
+	for key := range entry.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := entry.Data[key]
+		if err, ok := value.(error); ok {
+			value = err.Error()
+		}
+		record.Fields = append(record.Fields, logging.Field{Key: key, Value: fmt.Sprint(value)})
+	}
+
+	ctx := entry.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	h.sink.Send(ctx, record)
+	if entry.Level == logrus.PanicLevel {
+		// the logger panics after the hooks fired
+		return h.sink.Flush(context.Background())
+	}
+	return nil
+}
+
+// New create a hook sends entries at or above the level to sink, the sink is
+// flushed by the exit handler of logrus before the fatal exits
+func New(sink *logging.Sink, level logrus.Level) *Hook {
+	logrus.RegisterExitHandler(func() {
+		_ = sink.Flush(context.Background())
+	})
+
+	var levels []logrus.Level
+	for _, l := range logrus.AllLevels {
+		if l <= level {
+			levels = append(levels, l)
+		}
+	}
+	return &Hook{sink: sink, levels: levels}
+}
@@ -0,0 +1,79 @@
+package logrusrobot
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/wjiec/workrobot"
+	"github.com/wjiec/workrobot/logging"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// newGateway create a gateway records the received messages
+func newGateway(t *testing.T) (*workrobot.Client, func() []string) {
+	var mu sync.Mutex
+	var received []string
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bs, _ := ioutil.ReadAll(r.Body)
+
+		mu.Lock()
+		received = append(received, string(bs))
+		mu.Unlock()
+		_, _ = fmt.Fprint(w, `{"errcode":0,"errmsg":"ok"}`)
+	}))
+	t.Cleanup(gateway.Close)
+
+	client, _ := workrobot.NewClient("", workrobot.WithWebhook(gateway.URL))
+	return client, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), received...)
+	}
+}
+
+func TestHook(t *testing.T) {
+	client, received := newGateway(t)
+	sink := logging.NewSink(client)
+	hook := New(sink, logrus.ErrorLevel)
+	assert.Equal(t, []logrus.Level{logrus.PanicLevel, logrus.FatalLevel, logrus.ErrorLevel}, hook.Levels())
+
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	logger.AddHook(hook)
+
+	logger.Warn("ignored")
+	logger.WithError(errors.New("connection refused")).WithField("service", "api").Error("request failed")
+	assert.NoError(t, sink.Close())
+
+	if assert.Len(t, received(), 1) {
+		assert.Contains(t, received()[0], "ERROR\\u003c/font\\u003e** request failed")
+		assert.Contains(t, received()[0], "error: connection refused")
+		assert.Contains(t, received()[0], "service: api")
+	}
+}
+
+func TestHook_Exit(t *testing.T) {
+	client, received := newGateway(t)
+
+	var code int
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	logger.ExitFunc = func(c int) { code = c }
+	logger.AddHook(New(logging.NewSink(client), logrus.ErrorLevel))
+
+	logger.Fatal("cannot listen")
+	assert.Equal(t, 1, code)
+	assert.Len(t, received(), 1)
+
+	assert.Panics(t, func() { logger.Panic("out of memory") })
+	if assert.Len(t, received(), 2) {
+		assert.Contains(t, received()[1], "PANIC\\u003c/font\\u003e** out of memory")
+	}
+}
@@ -0,0 +1,99 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"runtime"
+)
+
+// Handler represents a slog.Handler sends records at or above the level to sink
+type Handler struct {
+	sink   *Sink
+	level  slog.Leveler
+	fields []Field
+	groups []string
+}
+
+// Enabled implements slog.Handler
+func (h *Handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+// Handle implements slog.Handler, records are sent in background and errors are
+// reported to the error handler of sink
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	record := &Record{
+		Time:    r.Time,
+		Level:   r.Level.String(),
+		Message: r.Message,
+		Fields:  append([]Field(nil), h.fields...),
+	}
+	if r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		record.Source = fmt.Sprintf("%s:%d", filepath.Base(frame.File), frame.Line)
+	}
+
+	r.Attrs(func(attr slog.Attr) bool {
+		record.Fields = appendAttr(record.Fields, h.groups, attr)
+		return true
+	})
+
+	h.sink.Send(ctx, record)
+	return nil
+}
+
+// WithAttrs implements slog.Handler
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.fields = append([]Field(nil), h.fields...)
+	for _, attr := range attrs {
+		clone.fields = appendAttr(clone.fields, h.groups, attr)
+	}
+	return &clone
+}
+
+// WithGroup implements slog.Handler
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	clone := *h
+	clone.groups = append(append([]string(nil), h.groups...), name)
+	return &clone
+}
+
+// appendAttr flatten the attribute into fields, keys of group members are
+// qualified by the group names
+func appendAttr(fields []Field, groups []string, attr slog.Attr) []Field {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return fields
+	}
+
+	if attr.Value.Kind() == slog.KindGroup {
+		if attr.Key != "" {
+			groups = append(append([]string(nil), groups...), attr.Key)
+		}
+		for _, member := range attr.Value.Group() {
+			fields = appendAttr(fields, groups, member)
+		}
+		return fields
+	}
+
+	key := attr.Key
+	for i := len(groups) - 1; i >= 0; i-- {
+		key = groups[i] + "." + key
+	}
+	return append(fields, Field{Key: key, Value: attr.Value.String()})
+}
+
+// NewHandler create a slog.Handler sends records at or above the level to sink
+func NewHandler(sink *Sink, level slog.Leveler) *Handler {
+	if level == nil {
+		level = slog.LevelError
+	}
+	return &Handler{sink: sink, level: level}
+}
@@ -0,0 +1,28 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler(t *testing.T) {
+	client, received := newGateway(t)
+
+	sink := NewSink(client)
+	logger := slog.New(NewHandler(sink, slog.LevelWarn))
+	logger.Info("ignored")
+
+	assert.True(t, logger.Enabled(context.Background(), slog.LevelError))
+	logger.With("service", "api").WithGroup("req").Error("request failed", "path", "/users", slog.Group("user", "id", 1))
+	assert.NoError(t, sink.Close())
+	if assert.Len(t, received(), 1) {
+		assert.Contains(t, received()[0], "ERROR\\u003c/font\\u003e** request failed")
+		assert.Contains(t, received()[0], "service: api")
+		assert.Contains(t, received()[0], "req.path: /users")
+		assert.Contains(t, received()[0], "req.user.id: 1")
+		assert.Contains(t, received()[0], "slog_test.go:")
+	}
+}
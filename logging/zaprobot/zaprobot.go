@@ -0,0 +1,86 @@
+// Package zaprobot implements a zapcore.Core sends log entries to robot,
+// it is usually teed with the existing core.
+//
+//	logger := zap.New(zapcore.NewTee(core, zaprobot.New(sink, zap.ErrorLevel)))
+package zaprobot
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/wjiec/workrobot/logging"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Core represents a zapcore.Core sends entries to sink
+type Core struct {
+	zapcore.LevelEnabler
+
+	sink   *logging.Sink
+	fields []zapcore.Field
+}
+
+// With implements zapcore.Core
+func (c *Core) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.fields = append(append([]zapcore.Field(nil), c.fields...), fields...)
+	return &clone
+}
+
+// Check implements zapcore.Core
+func (c *Core) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+// Write implements zapcore.Core, entries are sent in background and errors are
+// reported to the error handler of sink, entries above error level are flushed
+// before returning
+func (c *Core) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	encoder := zapcore.NewMapObjectEncoder()
+	for _, field := range c.fields {
+		field.AddTo(encoder)
+	}
+	for _, field := range fields {
+		field.AddTo(encoder)
+	}
+
+	record := &logging.Record{Time: entry.Time, Level: entry.Level.String(), Message: entry.Message}
+	if entry.Caller.Defined {
+		record.Source = entry.Caller.TrimmedPath()
+	}
+
+	keys := make([]string, 0, len(encoder.Fields))
+	for key := range encoder.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		record.Fields = append(record.Fields, logging.Field{Key: key, Value: fmt.Sprint(encoder.Fields[key])})
+	}
+	if entry.Stack != "" {
+		record.Fields = append(record.Fields, logging.Field{Key: "stacktrace", Value: entry.Stack})
+	}
+
+	c.sink.Send(context.Background(), record)
+	if entry.Level > zapcore.ErrorLevel {
+		// the process is going to panic or exit after the entry written
+		return c.Sync()
+	}
+	return nil
+}
+
+// Sync implements zapcore.Core, waits until the queued entries are sent
+func (c *Core) Sync() error {
+	return c.sink.Flush(context.Background())
+}
+
+// New create a core sends entries enabled by level to sink
+func New(sink *logging.Sink, level zapcore.LevelEnabler) *Core {
+	return &Core{LevelEnabler: level, sink: sink}
+}
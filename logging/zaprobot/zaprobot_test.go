@@ -0,0 +1,67 @@
+package zaprobot
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/wjiec/workrobot"
+	"github.com/wjiec/workrobot/logging"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// newGateway create a gateway records the received messages
+func newGateway(t *testing.T) (*workrobot.Client, func() []string) {
+	var mu sync.Mutex
+	var received []string
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bs, _ := ioutil.ReadAll(r.Body)
+
+		mu.Lock()
+		received = append(received, string(bs))
+		mu.Unlock()
+		_, _ = fmt.Fprint(w, `{"errcode":0,"errmsg":"ok"}`)
+	}))
+	t.Cleanup(gateway.Close)
+
+	client, _ := workrobot.NewClient("", workrobot.WithWebhook(gateway.URL))
+	return client, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), received...)
+	}
+}
+
+func TestCore(t *testing.T) {
+	client, received := newGateway(t)
+	logger := zap.New(New(logging.NewSink(client), zap.ErrorLevel), zap.AddCaller())
+
+	logger.Warn("ignored")
+	logger.With(zap.String("service", "api")).Error("request failed", zap.Int("status", 500))
+	assert.NoError(t, logger.Sync())
+
+	if assert.Len(t, received(), 1) {
+		assert.Contains(t, received()[0], "ERROR\\u003c/font\\u003e** request failed")
+		assert.Contains(t, received()[0], "service: api")
+		assert.Contains(t, received()[0], "status: 500")
+		assert.Contains(t, received()[0], "zaprobot_test.go:")
+	}
+}
+
+func TestCore_Panic(t *testing.T) {
+	client, received := newGateway(t)
+	logger := zap.New(New(logging.NewSink(client), zap.ErrorLevel))
+
+	logger.DPanic("invariant broken")
+	assert.Len(t, received(), 1)
+
+	assert.Panics(t, func() { logger.Panic("out of memory") })
+	if assert.Len(t, received(), 2) {
+		assert.Contains(t, received()[1], "PANIC\\u003c/font\\u003e** out of memory")
+	}
+}
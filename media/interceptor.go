@@ -0,0 +1,43 @@
+package media
+
+import (
+	"context"
+	"time"
+)
+
+// Upload represents a single media upload passing through the interceptors
+type Upload struct {
+	// Endpoint is the upload gateway address the file sent to
+	Endpoint string
+	// Filename is the name of file to be uploaded
+	Filename string
+	// Size is the size of file content in bytes
+	Size int64
+	// Attempt is the number of times the file has been sent to the gateway
+	Attempt int
+	// Latency is the duration of the last gateway request
+	Latency time.Duration
+	// Media is the uploaded media of the last attempt, nil when upload failed
+	Media *Media
+
+	body        []byte
+	contentType string
+}
+
+// Handler represents the next step to upload a media
+type Handler func(ctx context.Context, up *Upload) error
+
+// Interceptor represents a hook wrapped around each upload, it should
+// call next to continue the upload, or returns directly to skip it
+type Interceptor func(ctx context.Context, up *Upload, next Handler) error
+
+// chain wraps the handler with interceptors, the first interceptor is the outermost
+func chain(interceptors []Interceptor, handler Handler) Handler {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], handler
+		handler = func(ctx context.Context, up *Upload) error {
+			return interceptor(ctx, up, next)
+		}
+	}
+	return handler
+}
@@ -2,6 +2,7 @@ package media
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -11,6 +12,7 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"time"
 
 	uuid "github.com/satori/go.uuid"
 
@@ -21,10 +23,17 @@ import (
 type Uploader struct {
 	hc       *http.Client
 	endpoint string
+
+	interceptors []Interceptor
 }
 
 // UploadFromReader upload a wxUploadReceipt from reader
 func (u *Uploader) UploadFromReader(reader io.Reader) (*Media, error) {
+	return u.UploadFromReaderContext(context.Background(), reader)
+}
+
+// UploadFromReaderContext upload a wxUploadReceipt from reader with the context
+func (u *Uploader) UploadFromReaderContext(ctx context.Context, reader io.Reader) (*Media, error) {
 	var body bytes.Buffer
 	writer := multipart.NewWriter(&body)
 
@@ -38,7 +47,8 @@ func (u *Uploader) UploadFromReader(reader io.Reader) (*Media, error) {
 		return nil, errors.Wrap(err, "cannot create multipart")
 	}
 
-	if _, err := io.Copy(part, reader); err != nil {
+	size, err := io.Copy(part, reader)
+	if err != nil {
 		return nil, errors.Wrap(err, "reader unreadable")
 	}
 
@@ -46,24 +56,49 @@ func (u *Uploader) UploadFromReader(reader io.Reader) (*Media, error) {
 		return nil, errors.Wrap(err, "multipart not writable")
 	}
 
-	req, err := http.NewRequest(http.MethodPost, u.endpoint, &body)
-	if err != nil {
-		return nil, errors.Wrap(err, "unable to create request")
+	up := &Upload{Endpoint: u.endpoint, Filename: filepath.Base(filename), Size: size,
+		body: body.Bytes(), contentType: writer.FormDataContentType()}
+	if err := chain(u.interceptors, u.transmit)(ctx, up); err != nil {
+		return nil, err
 	}
-	req.Header.Set("Content-Type", writer.FormDataContentType())
-
-	return u.upload(req)
+	return up.Media, nil
 }
 
 // UploadFromFile upload a wxUploadReceipt from filename
 func (u *Uploader) UploadFromFile(filename string) (*Media, error) {
+	return u.UploadFromFileContext(context.Background(), filename)
+}
+
+// UploadFromFileContext upload a wxUploadReceipt from filename with the context
+func (u *Uploader) UploadFromFileContext(ctx context.Context, filename string) (*Media, error) {
 	f, err := os.Open(filename)
 	if err != nil {
 		return nil, errors.Wrap(err, "cannot open file")
 	}
 	defer func() { _ = f.Close() }()
 
-	return u.UploadFromReader(f)
+	return u.UploadFromReaderContext(ctx, f)
+}
+
+// namedReader represents a reader with the filename of upload
+type namedReader struct {
+	*bytes.Reader
+	name string
+}
+
+// Name returns the filename of reader
+func (r *namedReader) Name() string {
+	return r.name
+}
+
+// UploadFromBytes upload data as a file named filename
+func (u *Uploader) UploadFromBytes(filename string, data []byte) (*Media, error) {
+	return u.UploadFromBytesContext(context.Background(), filename, data)
+}
+
+// UploadFromBytesContext upload data as a file named filename with the context
+func (u *Uploader) UploadFromBytesContext(ctx context.Context, filename string, data []byte) (*Media, error) {
+	return u.UploadFromReaderContext(ctx, &namedReader{Reader: bytes.NewReader(data), name: filename})
 }
 
 // wxUploadReceipt represents an upload response
@@ -73,10 +108,43 @@ type wxUploadReceipt struct {
 	Type      string `json:"type"`
 	CreatedAt string `json:"created_at"`
 
+	Receipt
+}
+
+// Receipt represents an upload receipt from workWx, and returns as
+// error when the gateway rejects the file
+type Receipt struct {
 	Code    int    `json:"errcode"`
 	Message string `json:"errmsg"`
 }
 
+// Error build error message and returns when error occurs
+func (r *Receipt) Error() string {
+	return fmt.Sprintf("%d: %s", r.Code, r.Message)
+}
+
+// transmit send the upload request to the gateway and record the media
+func (u *Uploader) transmit(ctx context.Context, up *Upload) error {
+	up.Attempt++
+	up.Media = nil
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.endpoint, bytes.NewReader(up.body))
+	if err != nil {
+		return errors.Wrap(err, "unable to create request")
+	}
+	req.Header.Set("Content-Type", up.contentType)
+
+	start := time.Now()
+	media, err := u.upload(req)
+	up.Latency = time.Since(start)
+	if err != nil {
+		return err
+	}
+
+	up.Media = media
+	return nil
+}
+
 // upload execute an request and check http response
 func (u *Uploader) upload(req *http.Request) (*Media, error) {
 	resp, err := u.hc.Do(req)
@@ -96,7 +164,7 @@ func (u *Uploader) upload(req *http.Request) (*Media, error) {
 	}
 
 	if receipt.Code != 0 {
-		return nil, errors.New(fmt.Sprintf("%d: %s", receipt.Code, receipt.Message))
+		return nil, &receipt.Receipt
 	}
 
 	ts, err := strconv.ParseInt(receipt.CreatedAt, 10, 64)
@@ -114,7 +182,8 @@ type Media struct {
 	CreatedAt int64  `json:"created_at"`
 }
 
-// New create a wxUploadReceipt uploader
-func New(hc *http.Client, endpoint string) *Uploader {
-	return &Uploader{hc: hc, endpoint: endpoint}
+// New create a wxUploadReceipt uploader, the interceptors are wrapped
+// around each upload and the first one is the outermost
+func New(hc *http.Client, endpoint string, interceptors ...Interceptor) *Uploader {
+	return &Uploader{hc: hc, endpoint: endpoint, interceptors: interceptors}
 }
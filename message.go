@@ -17,8 +17,12 @@ var (
 	ErrMessageTooLong = errors.New("message too long")
 	// ErrImageTooLarge represents the image size exceeds the limit(2m)
 	ErrImageTooLarge = errors.New("image too large")
+	// ErrUnsupportedImage represents the image is neither jpg nor png
+	ErrUnsupportedImage = errors.New("unsupported image format, only jpg and png allowed")
 	// ErrTooManyArticle represents articles count more than 8
 	ErrTooManyArticle = errors.New("too many articles")
+	// ErrTooManyCardField represents template card fields or jumps more than the limit
+	ErrTooManyCardField = errors.New("too many card fields")
 )
 
 const (
@@ -26,6 +30,13 @@ const (
 	MarkdownMessageMaxLength = 4096
 	MaxImageFileSize         = 2 * 1024 * 1024 // 2M
 	MaxArticleCount          = 8
+	MaxCardFieldCount        = 6
+	MaxCardJumpCount         = 3
+
+	// limits of template card text in characters
+	CardTitleMaxLength       = 26
+	CardDescriptionMaxLength = 30
+	CardSubTitleMaxLength    = 112
 )
 
 // Messager represents a message will sent
@@ -190,6 +201,8 @@ func NewMarkdown(lines ...interface{}) (*Markdown, error) {
 type Image struct {
 	len  int
 	data []byte
+
+	compression *Compression
 }
 
 // From read image data from reader
@@ -198,15 +211,19 @@ func (img *Image) From(reader io.Reader) error {
 	img.data = make([]byte, MaxImageFileSize/16)
 	for len(img.data) <= MaxImageFileSize {
 		rn, err := reader.Read(img.data[img.len:])
+		img.len += rn
 		if err != nil {
 			if err == io.EOF {
+				if DetectImageFormat(img.data[:img.len]) == "" {
+					img.len, img.data = 0, nil
+					return ErrUnsupportedImage
+				}
 				return nil
 			}
 
 			return err
 		}
 
-		img.len += rn
 		if img.len == len(img.data) {
 			if 2*img.len > MaxImageFileSize {
 				break
@@ -239,8 +256,16 @@ func (img *Image) Message() []byte {
 }
 
 // NewImage create an image message from reader
-func NewImage(reader io.Reader) (*Image, error) {
+func NewImage(reader io.Reader, options ...ImageOption) (*Image, error) {
+	var opts imageOptions
+	for _, opt := range options {
+		opt(&opts)
+	}
+
 	var img Image
+	if opts.compress {
+		return &img, img.fromCompressed(reader)
+	}
 	return &img, img.From(reader)
 }
 
@@ -297,6 +322,72 @@ func NewMedia(mediaId string) *Media {
 	return &Media{mediaId: mediaId}
 }
 
+// TemplateCard represents a text notice template card message
+type TemplateCard struct {
+	title       string
+	description string
+	link        string
+
+	// Emphasis is the highlighted content with its description
+	Emphasis     string
+	EmphasisDesc string
+	// SubTitle is the secondary content below the title
+	SubTitle string
+
+	fields []*CardField
+	jumps  []*CardJump
+}
+
+// AddField add a key-value field into card, the value links to url if not empty
+func (c *TemplateCard) AddField(field *CardField) error {
+	if len(c.fields) >= MaxCardFieldCount {
+		return ErrTooManyCardField
+	}
+
+	if field.Url != "" {
+		field.Type = 1 // link
+	}
+	c.fields = append(c.fields, field)
+	return nil
+}
+
+// AddJump add a jump link at the bottom of card
+func (c *TemplateCard) AddJump(jump *CardJump) error {
+	if len(c.jumps) >= MaxCardJumpCount {
+		return ErrTooManyCardField
+	}
+
+	jump.Type = 1 // link
+	c.jumps = append(c.jumps, jump)
+	return nil
+}
+
+// Message implement Messager and build message with template card content
+func (c *TemplateCard) Message() []byte {
+	data := payload{MessageType: "template_card", TemplateCard: &templateCard{
+		CardType:  "text_notice",
+		MainTitle: &cardTitle{Title: c.title, Description: c.description},
+		SubTitle:  c.SubTitle,
+		Fields:    c.fields,
+		Jumps:     c.jumps,
+		Action:    &cardAction{Type: 1, Url: c.link},
+	}}
+	if c.Emphasis != "" {
+		data.TemplateCard.Emphasis = &cardTitle{Title: c.Emphasis, Description: c.EmphasisDesc}
+	}
+
+	return data.Build()
+}
+
+// NewTemplateCard create a text notice template card links to url when clicked
+func NewTemplateCard(title, description, link string) (*TemplateCard, error) {
+	if title == "" || link == "" {
+		return nil, errors.New("required card title and link")
+	}
+
+	return &TemplateCard{title: title, description: description, link: link}, nil
+}
+
 // payload represents a send request payload
 // see https://work.weixin.qq.com/api/doc/90000/90136/91770#消息类型及数据格式
 type payload struct {
@@ -306,6 +397,8 @@ type payload struct {
 	Image       *image    `json:"image,omitempty"`
 	Card        *card     `json:"card"`
 	Media       *media    `json:"file"`
+
+	TemplateCard *templateCard `json:"template_card,omitempty"`
 }
 
 // Build build payload to json data
@@ -356,3 +449,79 @@ type card struct {
 type media struct {
 	MediaId string `json:"media_id"`
 }
+
+// RawMessage represents a prebuilt message payload
+type RawMessage []byte
+
+// Message implement Messager and returns the payload as is
+func (m RawMessage) Message() []byte {
+	return m
+}
+
+// StripMentionAll returns a message without mentioning all group members,
+// the message is returned unchanged when it does not mention all
+func StripMentionAll(msg Messager) Messager {
+	var data payload
+	if err := json.Unmarshal(msg.Message(), &data); err != nil || data.Text == nil {
+		return msg
+	}
+
+	members, mobiles := withoutAll(data.Text.MentionMembers), withoutAll(data.Text.MentionMobiles)
+	if len(members) == len(data.Text.MentionMembers) && len(mobiles) == len(data.Text.MentionMobiles) {
+		return msg
+	}
+
+	data.Text.MentionMembers, data.Text.MentionMobiles = members, mobiles
+	return RawMessage(data.Build())
+}
+
+// withoutAll removes the @all from mentions
+func withoutAll(mentions []string) (results []string) {
+	for _, mention := range mentions {
+		if mention != "@all" {
+			results = append(results, mention)
+		}
+	}
+	return
+}
+
+// CardField represents a key-value field of template card
+// see https://developer.work.weixin.qq.com/document/path/91770#文本通知模版卡片
+type CardField struct {
+	Key   string `json:"keyname"`
+	Value string `json:"value,omitempty"`
+	Url   string `json:"url,omitempty"`
+	Type  int    `json:"type,omitempty"`
+}
+
+// CardJump represents a jump link of template card
+// see https://developer.work.weixin.qq.com/document/path/91770#文本通知模版卡片
+type CardJump struct {
+	Title string `json:"title"`
+	Url   string `json:"url"`
+	Type  int    `json:"type"`
+}
+
+// cardTitle represents a title with description of template card
+type cardTitle struct {
+	Title       string `json:"title,omitempty"`
+	Description string `json:"desc,omitempty"`
+}
+
+// cardAction represents the click action of template card
+type cardAction struct {
+	Type int    `json:"type"`
+	Url  string `json:"url"`
+}
+
+// templateCard represents a template card message data
+// see https://developer.work.weixin.qq.com/document/path/91770#模版卡片类型
+type templateCard struct {
+	CardType  string       `json:"card_type"`
+	MainTitle *cardTitle   `json:"main_title"`
+	Emphasis  *cardTitle   `json:"emphasis_content,omitempty"`
+	SubTitle  string       `json:"sub_title_text,omitempty"`
+	Fields    []*CardField `json:"horizontal_content_list,omitempty"`
+	Jumps     []*CardJump  `json:"jump_list,omitempty"`
+	Action    *cardAction  `json:"card_action"`
+}
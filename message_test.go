@@ -57,3 +57,33 @@ func TestNewMedia(t *testing.T) {
 
 	assert.Equal(t, "media-id", msg.mediaId)
 }
+
+func TestStripMentionAll(t *testing.T) {
+	msg, _ := NewText("hello")
+	msg.MentionMember("jayson").MentionAll(true)
+
+	stripped := StripMentionAll(msg)
+	assert.NotContains(t, string(stripped.Message()), "@all")
+	assert.Contains(t, string(stripped.Message()), "jayson")
+
+	markdown, _ := NewMarkdown("hello")
+	assert.Equal(t, markdown, StripMentionAll(markdown))
+}
+
+func TestNewTemplateCard(t *testing.T) {
+	_, err := NewTemplateCard("", "", "https://example.com")
+	assert.NotNil(t, err)
+
+	card, err := NewTemplateCard("title", "desc", "https://example.com")
+	if assert.NoError(t, err) {
+		for i := 0; i < MaxCardFieldCount; i++ {
+			assert.NoError(t, card.AddField(&CardField{Key: "key", Value: "value"}))
+		}
+		assert.ErrorIs(t, card.AddField(&CardField{Key: "key"}), ErrTooManyCardField)
+		assert.NoError(t, card.AddJump(&CardJump{Title: "view", Url: "https://example.com"}))
+
+		assert.Equal(t, "template_card", MessageType(card))
+		assert.Contains(t, string(card.Message()), `"card_type":"text_notice"`)
+		assert.Contains(t, string(card.Message()), `"jump_list":[{"title":"view","url":"https://example.com","type":1}]`)
+	}
+}
@@ -0,0 +1,195 @@
+// Package metrics instruments robot clients and uploaders with prometheus
+// metrics, the Collector can be registered on any prometheus registry.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/wjiec/workrobot"
+	"github.com/wjiec/workrobot/media"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// default namespace of all metrics
+	DefaultNamespace = "workrobot"
+)
+
+const (
+	ResultSuccess      = "success"
+	ResultGatewayError = "gateway_error"
+	ResultCanceled     = "canceled"
+	ResultError        = "error"
+)
+
+const (
+	operationSend   = "send"
+	operationUpload = "upload"
+)
+
+// Collector represents a prometheus collector for robot clients
+type Collector struct {
+	namespace   string
+	constLabels prometheus.Labels
+
+	messages *prometheus.CounterVec
+	errcodes *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+	payload  *prometheus.HistogramVec
+	uploads  *prometheus.CounterVec
+	sizes    prometheus.Histogram
+	wait     prometheus.Histogram
+}
+
+// Describe implements prometheus.Collector
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, collector := range c.collectors() {
+		collector.Describe(ch)
+	}
+}
+
+// Collect implements prometheus.Collector
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	for _, collector := range c.collectors() {
+		collector.Collect(ch)
+	}
+}
+
+// collectors returns all underlying metrics
+func (c *Collector) collectors() []prometheus.Collector {
+	return []prometheus.Collector{c.messages, c.errcodes, c.latency, c.payload, c.uploads, c.sizes, c.wait}
+}
+
+// Interceptor returns an interceptor that records the message deliveries, it
+// should be registered as the outermost interceptor to observe the wait time
+func (c *Collector) Interceptor() workrobot.Interceptor {
+	return func(ctx context.Context, call *workrobot.Call, next workrobot.Handler) error {
+		start := time.Now()
+		msgType := call.Type()
+		c.payload.WithLabelValues(msgType).Observe(float64(len(call.Payload())))
+
+		err := next(ctx, call)
+		if !call.Dispatched.IsZero() {
+			c.wait.Observe(call.Dispatched.Sub(start).Seconds())
+			c.latency.WithLabelValues(operationSend).Observe(call.Latency.Seconds())
+		}
+
+		var receipt *workrobot.Receipt
+		if errors.As(err, &receipt) {
+			c.errcodes.WithLabelValues(operationSend, strconv.Itoa(receipt.Code)).Inc()
+		}
+		c.messages.WithLabelValues(msgType, result(err, receipt != nil)).Inc()
+
+		return err
+	}
+}
+
+// UploadInterceptor returns an interceptor that records the media uploads
+func (c *Collector) UploadInterceptor() media.Interceptor {
+	return func(ctx context.Context, up *media.Upload, next media.Handler) error {
+		c.sizes.Observe(float64(up.Size))
+
+		err := next(ctx, up)
+		if up.Attempt != 0 {
+			c.latency.WithLabelValues(operationUpload).Observe(up.Latency.Seconds())
+		}
+
+		var receipt *media.Receipt
+		if errors.As(err, &receipt) {
+			c.errcodes.WithLabelValues(operationUpload, strconv.Itoa(receipt.Code)).Inc()
+		}
+		c.uploads.WithLabelValues(result(err, receipt != nil)).Inc()
+
+		return err
+	}
+}
+
+// Instrument returns a client option that registers both the send
+// and upload interceptors of collector on the robot
+func (c *Collector) Instrument() workrobot.ClientOption {
+	return func(client *workrobot.Client) error {
+		if err := workrobot.WithInterceptor(c.Interceptor())(client); err != nil {
+			return err
+		}
+		return workrobot.WithUploadInterceptor(c.UploadInterceptor())(client)
+	}
+}
+
+// result returns the result label of error
+func result(err error, rejected bool) string {
+	switch {
+	case err == nil:
+		return ResultSuccess
+	case rejected:
+		return ResultGatewayError
+	case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+		return ResultCanceled
+	default:
+		return ResultError
+	}
+}
+
+// Option represents additional collector configuration
+type Option func(*Collector)
+
+// WithNamespace override the namespace of metrics
+func WithNamespace(namespace string) Option {
+	return func(c *Collector) {
+		c.namespace = namespace
+	}
+}
+
+// WithConstLabels attach constant labels to all metrics, it is required
+// to distinguish the collectors registered on the same registry
+func WithConstLabels(labels prometheus.Labels) Option {
+	return func(c *Collector) {
+		c.constLabels = labels
+	}
+}
+
+// New create a collector for robot clients
+func New(options ...Option) *Collector {
+	c := &Collector{namespace: DefaultNamespace}
+	for _, opt := range options {
+		opt(c)
+	}
+
+	c.messages = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: c.namespace, ConstLabels: c.constLabels,
+		Name: "messages_sent_total", Help: "Total number of messages sent by type and result.",
+	}, []string{"type", "result"})
+	c.errcodes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: c.namespace, ConstLabels: c.constLabels,
+		Name: "gateway_errors_total", Help: "Total number of errcodes returned by the gateway.",
+	}, []string{"operation", "errcode"})
+	c.latency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: c.namespace, ConstLabels: c.constLabels,
+		Name: "request_duration_seconds", Help: "Latency of requests to the gateway.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"operation"})
+	c.payload = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: c.namespace, ConstLabels: c.constLabels,
+		Name: "message_payload_bytes", Help: "Size of message payloads in bytes.",
+		Buckets: prometheus.ExponentialBuckets(64, 4, 8),
+	}, []string{"type"})
+	c.uploads = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: c.namespace, ConstLabels: c.constLabels,
+		Name: "uploads_total", Help: "Total number of media uploads by result.",
+	}, []string{"result"})
+	c.sizes = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: c.namespace, ConstLabels: c.constLabels,
+		Name: "upload_size_bytes", Help: "Size of uploaded files in bytes.",
+		Buckets: prometheus.ExponentialBuckets(1024, 4, 10),
+	})
+	c.wait = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: c.namespace, ConstLabels: c.constLabels,
+		Name: "send_wait_seconds", Help: "Time spent before the first gateway request, e.g. in rate limiters.",
+		Buckets: prometheus.DefBuckets,
+	})
+
+	return c
+}
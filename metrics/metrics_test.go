@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/wjiec/workrobot"
+	"github.com/wjiec/workrobot/media"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollector(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "upload") {
+			_, _ = fmt.Fprint(w, `{"errcode":0,"errmsg":"ok","type":"file","media_id":"id","created_at":"1380000000"}`)
+		} else if r.URL.Query().Get("key") == "bad" {
+			_, _ = fmt.Fprint(w, `{"errcode":93000,"errmsg":"invalid webhook url"}`)
+		} else {
+			_, _ = fmt.Fprint(w, `{"errcode":0,"errmsg":"ok"}`)
+		}
+	}))
+	defer server.Close()
+
+	collector := New(WithConstLabels(prometheus.Labels{"robot": "test"}))
+	registry := prometheus.NewRegistry()
+	if !assert.NoError(t, registry.Register(collector)) {
+		return
+	}
+
+	good, _ := workrobot.NewClient("", workrobot.WithWebhook(server.URL+"/send?key=good"), collector.Instrument())
+	bad, _ := workrobot.NewClient("", workrobot.WithWebhook(server.URL+"/send?key=bad"), collector.Instrument())
+
+	text, _ := workrobot.NewText("hello")
+	assert.NoError(t, good.Send(text))
+	assert.Error(t, bad.Send(text))
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(collector.messages.WithLabelValues("text", ResultSuccess)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(collector.messages.WithLabelValues("text", ResultGatewayError)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(collector.errcodes.WithLabelValues("send", "93000")))
+
+	uploader := media.New(http.DefaultClient, server.URL+"/upload", collector.UploadInterceptor())
+	_, err := uploader.UploadFromReader(strings.NewReader("hello world"))
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, testutil.ToFloat64(collector.uploads.WithLabelValues(ResultSuccess)))
+
+	count, err := testutil.GatherAndCount(registry, "workrobot_send_wait_seconds", "workrobot_message_payload_bytes")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
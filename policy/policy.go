@@ -0,0 +1,228 @@
+// Package policy implements quiet hours and routing policies in front of robot,
+// messages are matched by the labels from workrobot.WithLabels.
+package policy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/wjiec/workrobot"
+	"github.com/wjiec/workrobot/schedule"
+
+	"github.com/pkg/errors"
+)
+
+// Action represents how the message is handled by policy
+type Action int
+
+const (
+	// Deliver sends the message as is
+	Deliver Action = iota
+	// Delay sends the message when the quiet hours end
+	Delay
+	// Downgrade sends the message without mentioning all members
+	Downgrade
+	// Reroute sends the message to the target robot
+	Reroute
+)
+
+// QuietHours represents a daily time window in the location, the
+// window spans midnight when Start is after End
+type QuietHours struct {
+	Start    time.Duration
+	End      time.Duration
+	Location *time.Location
+	// Weekdays are the days of the window starts, empty means everyday
+	Weekdays []time.Weekday
+}
+
+// Contains checks whether the time is in quiet hours
+func (q *QuietHours) Contains(t time.Time) bool {
+	_, found := q.window(t)
+	return found
+}
+
+// Until returns the time of quiet hours end, or t if not in quiet hours
+func (q *QuietHours) Until(t time.Time) time.Time {
+	if end, found := q.window(t); found {
+		return end
+	}
+	return t
+}
+
+// window returns the end of window contains the time
+func (q *QuietHours) window(t time.Time) (time.Time, bool) {
+	if q.Location != nil {
+		t = t.In(q.Location)
+	}
+
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	// the window may start yesterday when it spans midnight
+	for _, day := range []time.Time{midnight.AddDate(0, 0, -1), midnight} {
+		if !q.activeOn(day.Weekday()) {
+			continue
+		}
+
+		start, end := day.Add(q.Start), day.Add(q.End)
+		if q.End <= q.Start {
+			end = day.AddDate(0, 0, 1).Add(q.End)
+		}
+		if !t.Before(start) && t.Before(end) {
+			return end, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// activeOn checks whether the window starts on the weekday
+func (q *QuietHours) activeOn(weekday time.Weekday) bool {
+	if len(q.Weekdays) == 0 {
+		return true
+	}
+
+	for _, wd := range q.Weekdays {
+		if wd == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseQuietHours parse window like "22:00-08:00" in the location
+func ParseQuietHours(window string, loc *time.Location) (*QuietHours, error) {
+	parts := strings.Split(window, "-")
+	if len(parts) != 2 {
+		return nil, errors.Errorf("invalid quiet hours %q", window)
+	}
+
+	start, err := parseClock(parts[0])
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseClock(parts[1])
+	if err != nil {
+		return nil, err
+	}
+
+	return &QuietHours{Start: start, End: end, Location: loc}, nil
+}
+
+// parseClock parse clock like 08:30 to the duration since midnight
+func parseClock(clock string) (time.Duration, error) {
+	var hour, minute int
+	if _, err := fmt.Sscanf(strings.TrimSpace(clock), "%d:%d", &hour, &minute); err != nil {
+		return 0, errors.Wrapf(err, "invalid clock %q", clock)
+	}
+	if hour < 0 || hour > 24 || minute < 0 || minute > 59 {
+		return 0, errors.Errorf("invalid clock %q", clock)
+	}
+	return time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute, nil
+}
+
+// Rule represents an action applies to the matched messages
+type Rule struct {
+	// Match selects messages have all the labels, empty matches all messages
+	Match workrobot.Labels
+	// Quiet is the window rule takes effect, nil means always
+	Quiet  *QuietHours
+	Action Action
+	// Target is the robot messages rerouted to
+	Target *workrobot.Client
+}
+
+// matches checks whether the rule applies to the message at the time
+func (r *Rule) matches(labels workrobot.Labels, now time.Time) bool {
+	for k, v := range r.Match {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return r.Quiet == nil || r.Quiet.Contains(now)
+}
+
+// Policy represents a policy layer sends messages by the first matched rule,
+// messages not matched by any rule are delivered
+type Policy struct {
+	client    *workrobot.Client
+	rules     []Rule
+	clock     schedule.Clock
+	scheduler *schedule.Scheduler
+}
+
+// Send send messages by the rules with the labels of context
+func (p *Policy) Send(ctx context.Context, messages ...workrobot.Messager) error {
+	for _, msg := range messages {
+		if err := p.send(ctx, msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// send send single message by the first matched rule
+func (p *Policy) send(ctx context.Context, msg workrobot.Messager) error {
+	now := p.clock.Now()
+	labels := workrobot.LabelsFromContext(ctx)
+	for i := range p.rules {
+		rule := &p.rules[i]
+		if !rule.matches(labels, now) {
+			continue
+		}
+
+		switch rule.Action {
+		case Delay:
+			if rule.Quiet == nil {
+				return p.client.SendContext(ctx, msg)
+			}
+			_, err := p.scheduler.At(rule.Quiet.Until(now), msg)
+			return err
+		case Downgrade:
+			return p.client.SendContext(ctx, workrobot.StripMentionAll(msg))
+		case Reroute:
+			return rule.Target.SendContext(ctx, msg)
+		default:
+			return p.client.SendContext(ctx, msg)
+		}
+	}
+
+	return p.client.SendContext(ctx, msg)
+}
+
+// Option represents additional policy configuration
+type Option func(*Policy)
+
+// WithClock override the clock of policy
+func WithClock(clock schedule.Clock) Option {
+	return func(p *Policy) {
+		p.clock = clock
+	}
+}
+
+// WithScheduler sets the scheduler of delayed messages, which must be running
+// and send through the same client, it is required by Delay rules
+func WithScheduler(scheduler *schedule.Scheduler) Option {
+	return func(p *Policy) {
+		p.scheduler = scheduler
+	}
+}
+
+// New create a policy sends messages through the client by rules
+func New(client *workrobot.Client, rules []Rule, options ...Option) (*Policy, error) {
+	p := &Policy{client: client, rules: rules, clock: schedule.SystemClock}
+	for _, opt := range options {
+		opt(p)
+	}
+
+	for i, rule := range p.rules {
+		switch {
+		case rule.Action == Delay && p.scheduler == nil:
+			return nil, errors.Errorf("rule %d: scheduler required to delay messages", i)
+		case rule.Action == Reroute && rule.Target == nil:
+			return nil, errors.Errorf("rule %d: target required to reroute messages", i)
+		}
+	}
+
+	return p, nil
+}
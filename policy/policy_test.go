@@ -0,0 +1,90 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/wjiec/workrobot"
+	"github.com/wjiec/workrobot/schedule"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time                          { return time.Time(c) }
+func (c fixedClock) NewTimer(d time.Duration) schedule.Timer { return schedule.SystemClock.NewTimer(d) }
+
+func TestQuietHours(t *testing.T) {
+	shanghai := time.FixedZone("CST", 8*3600)
+	quiet, err := ParseQuietHours("22:00-08:00", shanghai)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	night := time.Date(2021, 6, 1, 19, 0, 0, 0, time.UTC) // 03:00 in shanghai
+	assert.True(t, quiet.Contains(night))
+	assert.Equal(t, time.Date(2021, 6, 2, 8, 0, 0, 0, shanghai), quiet.Until(night))
+
+	day := time.Date(2021, 6, 1, 4, 0, 0, 0, time.UTC) // 12:00 in shanghai
+	assert.False(t, quiet.Contains(day))
+	assert.Equal(t, day, quiet.Until(day))
+
+	quiet.Weekdays = []time.Weekday{time.Saturday}
+	assert.False(t, quiet.Contains(night))
+
+	_, err = ParseQuietHours("22:00", shanghai)
+	assert.Error(t, err)
+}
+
+func TestPolicy(t *testing.T) {
+	received := map[string][]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bs, _ := ioutil.ReadAll(r.Body)
+		received[r.URL.Path] = append(received[r.URL.Path], string(bs))
+		_, _ = fmt.Fprint(w, `{"errcode":0,"errmsg":"ok"}`)
+	}))
+	defer server.Close()
+
+	primary, _ := workrobot.NewClient("", workrobot.WithWebhook(server.URL+"/primary"))
+	oncall, _ := workrobot.NewClient("", workrobot.WithWebhook(server.URL+"/oncall"))
+
+	clock := fixedClock(time.Date(2021, 6, 1, 3, 0, 0, 0, time.Local))
+	scheduler, _ := schedule.New(primary, schedule.WithClock(clock))
+
+	quiet, _ := ParseQuietHours("22:00-08:00", time.Local)
+	p, err := New(primary, []Rule{
+		{Match: workrobot.Labels{workrobot.LabelSeverity: "critical"}, Action: Reroute, Target: oncall},
+		{Match: workrobot.Labels{workrobot.LabelSeverity: "warning"}, Quiet: quiet, Action: Downgrade},
+		{Quiet: quiet, Action: Delay},
+	}, WithClock(clock), WithScheduler(scheduler))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	text, _ := workrobot.NewText("disk full")
+	text.MentionAll(true)
+
+	critical := workrobot.WithLabels(context.Background(), workrobot.Labels{workrobot.LabelSeverity: "critical"})
+	assert.NoError(t, p.Send(critical, text))
+	assert.Len(t, received["/oncall"], 1)
+
+	warning := workrobot.WithLabels(context.Background(), workrobot.Labels{workrobot.LabelSeverity: "warning"})
+	assert.NoError(t, p.Send(warning, text))
+	if assert.Len(t, received["/primary"], 1) {
+		assert.NotContains(t, received["/primary"][0], "@all")
+	}
+
+	assert.NoError(t, p.Send(context.Background(), text))
+	if entries := scheduler.Entries(); assert.Len(t, entries, 1) {
+		assert.Equal(t, time.Date(2021, 6, 1, 8, 0, 0, 0, time.Local), entries[0].Next)
+	}
+
+	_, err = New(primary, []Rule{{Action: Delay}})
+	assert.Error(t, err)
+}
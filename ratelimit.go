@@ -0,0 +1,34 @@
+package workrobot
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// throttle returns a handler waits for the rate limiter before each attempt
+func (c *Client) throttle(next Handler) Handler {
+	return func(ctx context.Context, call *Call) error {
+		if err := c.limiter.Wait(ctx); err != nil {
+			return err
+		}
+		return next(ctx, call)
+	}
+}
+
+// WithRateLimit limit the robot sends at most count messages per duration, and
+// blocks until allowed, the gateway limits 20 messages per minute
+//
+// see https://work.weixin.qq.com/api/doc/90000/90136/91770#消息发送频率限制
+func WithRateLimit(count int, per time.Duration) ClientOption {
+	return func(client *Client) error {
+		if count < 1 || per <= 0 {
+			return errors.New("invalid rate limit")
+		}
+
+		client.limiter = rate.NewLimiter(rate.Every(per/time.Duration(count)), count)
+		return nil
+	}
+}
@@ -0,0 +1,256 @@
+// Package relay implements an http server accepts simplified json or form
+// messages and forwards them to the named robots.
+//
+//	POST /send/{robot}
+//	Authorization: Bearer {token}
+//
+//	{"msgtype": "text", "content": "hello", "mentions": ["jayson"]}
+package relay
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/wjiec/workrobot"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// max size of request body, enough for a base64 encoded image
+	MaxRequestSize = 4 * workrobot.MaxImageFileSize
+)
+
+// errcodes of relay, the gateway errcodes are returned as is
+const (
+	ErrcodeOK           = 0
+	ErrcodeBadRequest   = 40000
+	ErrcodeUnauthorized = 40100
+	ErrcodeForbidden    = 40300
+	ErrcodeUnknownRobot = 40400
+	ErrcodeFailed       = 50000
+)
+
+// Request represents a simplified message request
+type Request struct {
+	MessageType string `json:"msgtype"`
+	Content     string `json:"content"`
+
+	Mentions []string `json:"mentions,omitempty"`
+	Mobiles  []string `json:"mobiles,omitempty"`
+	All      bool     `json:"all,omitempty"`
+
+	// Image is the base64 encoded jpg or png image
+	Image    string               `json:"image,omitempty"`
+	Articles []*workrobot.Article `json:"articles,omitempty"`
+}
+
+// Message build and validate the message of request
+func (req *Request) Message() (workrobot.Messager, error) {
+	switch req.MessageType {
+	case "", "text":
+		text, err := workrobot.NewText(req.Content)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, member := range req.Mentions {
+			text.MentionMember(member)
+		}
+		for _, mobile := range req.Mobiles {
+			text.MentionMobile(mobile)
+		}
+		text.MentionAll(req.All)
+		return text, nil
+	case "markdown":
+		var markdown workrobot.Markdown
+		return &markdown, markdown.RawContent(req.Content)
+	case "image":
+		return workrobot.NewImage(base64.NewDecoder(base64.StdEncoding, strings.NewReader(req.Image)))
+	case "news":
+		if len(req.Articles) == 0 {
+			return nil, errors.New("required at least one article")
+		}
+		return workrobot.NewCard(req.Articles...)
+	}
+	return nil, errors.Errorf("unsupported msgtype %q", req.MessageType)
+}
+
+// Response represents the result of relay
+type Response struct {
+	Code    int    `json:"errcode"`
+	Message string `json:"errmsg"`
+}
+
+// Server represents a relay server forwards messages to named robots
+type Server struct {
+	clients map[string]*workrobot.Client
+	tokens  map[string][]string
+
+	queryToken bool
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.Path, "/send/") {
+		reply(w, http.StatusNotFound, ErrcodeUnknownRobot, "not found")
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		reply(w, http.StatusMethodNotAllowed, ErrcodeBadRequest, "method not allowed")
+		return
+	}
+
+	robots, authorized := s.authorize(s.token(r))
+	if !authorized {
+		reply(w, http.StatusUnauthorized, ErrcodeUnauthorized, "invalid token")
+		return
+	}
+
+	robot := strings.TrimPrefix(r.URL.Path, "/send/")
+	if !allowed(robots, robot) {
+		reply(w, http.StatusForbidden, ErrcodeForbidden, "robot not allowed")
+		return
+	}
+
+	client, found := s.clients[robot]
+	if !found {
+		reply(w, http.StatusNotFound, ErrcodeUnknownRobot, "unknown robot "+strconv.Quote(robot))
+		return
+	}
+
+	req, err := parseRequest(r)
+	if err != nil {
+		reply(w, http.StatusBadRequest, ErrcodeBadRequest, err.Error())
+		return
+	}
+
+	msg, err := req.Message()
+	if err != nil {
+		reply(w, http.StatusBadRequest, ErrcodeBadRequest, err.Error())
+		return
+	}
+
+	if err := client.SendContext(r.Context(), msg); err != nil {
+		var receipt *workrobot.Receipt
+		if errors.As(err, &receipt) {
+			reply(w, http.StatusBadGateway, receipt.Code, receipt.Message)
+			return
+		}
+
+		reply(w, http.StatusBadGateway, ErrcodeFailed, err.Error())
+		return
+	}
+
+	reply(w, http.StatusOK, ErrcodeOK, "ok")
+}
+
+// parseRequest parse the json or form body of request
+func parseRequest(r *http.Request) (*Request, error) {
+	r.Body = http.MaxBytesReader(nil, r.Body, MaxRequestSize)
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "application/json" {
+		var req Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return nil, errors.Wrap(err, "invalid json body")
+		}
+		return &req, nil
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return nil, errors.Wrap(err, "invalid form body")
+	}
+
+	all, _ := strconv.ParseBool(r.PostForm.Get("all"))
+	return &Request{
+		MessageType: r.PostForm.Get("msgtype"),
+		Content:     r.PostForm.Get("content"),
+		Mentions:    r.PostForm["mention"],
+		Mobiles:     r.PostForm["mobile"],
+		All:         all,
+		Image:       r.PostForm.Get("image"),
+	}, nil
+}
+
+// token returns the bearer token of request, or the token parameter when
+// enabled by WithQueryToken
+func (s *Server) token(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimPrefix(auth, "Bearer ")
+	}
+	if s.queryToken {
+		return r.URL.Query().Get("token")
+	}
+	return ""
+}
+
+// authorize returns the robots allowed for the token, all tokens are compared
+// in constant time
+func (s *Server) authorize(token string) (robots []string, authorized bool) {
+	if token == "" {
+		return nil, false
+	}
+
+	for candidate, allowed := range s.tokens {
+		if subtle.ConstantTimeCompare([]byte(candidate), []byte(token)) == 1 {
+			robots, authorized = allowed, true
+		}
+	}
+	return
+}
+
+// allowed checks whether the robot in the allowed robots, * allows all robots
+func allowed(robots []string, robot string) bool {
+	for _, name := range robots {
+		if name == "*" || name == robot {
+			return true
+		}
+	}
+	return false
+}
+
+// reply writes the json response
+func reply(w http.ResponseWriter, status, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(&Response{Code: code, Message: message})
+}
+
+// Option represents additional server configuration
+type Option func(*Server)
+
+// WithToken authorize the token to send messages to robots, * allows all robots,
+// the empty token is ignored
+func WithToken(token string, robots ...string) Option {
+	return func(s *Server) {
+		if token == "" {
+			return
+		}
+		s.tokens[token] = append(s.tokens[token], robots...)
+	}
+}
+
+// WithQueryToken accepts the token from the token parameter of url for clients
+// cannot set headers, the token may leak into access logs and proxies
+func WithQueryToken() Option {
+	return func(s *Server) {
+		s.queryToken = true
+	}
+}
+
+// New create a relay server forwards messages to the named clients, requests
+// are rejected unless authorized by WithToken
+func New(clients map[string]*workrobot.Client, options ...Option) *Server {
+	s := &Server{clients: clients, tokens: make(map[string][]string)}
+	for _, opt := range options {
+		opt(s)
+	}
+	return s
+}
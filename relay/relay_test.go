@@ -0,0 +1,110 @@
+package relay
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/wjiec/workrobot"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer(t *testing.T) {
+	var received []string
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bs, _ := ioutil.ReadAll(r.Body)
+		received = append(received, string(bs))
+		if strings.Contains(string(bs), "rejected") {
+			_, _ = fmt.Fprint(w, `{"errcode":40008,"errmsg":"invalid message type"}`)
+			return
+		}
+		_, _ = fmt.Fprint(w, `{"errcode":0,"errmsg":"ok"}`)
+	}))
+	defer gateway.Close()
+
+	ops, _ := workrobot.NewClient("", workrobot.WithWebhook(gateway.URL))
+	dev, _ := workrobot.NewClient("", workrobot.WithWebhook(gateway.URL))
+	server := httptest.NewServer(New(map[string]*workrobot.Client{"ops": ops, "dev": dev},
+		WithToken("admin", "*"), WithToken("ci", "dev")))
+	defer server.Close()
+
+	post := func(path, token, contentType, body string) (int, *Response) {
+		req, _ := http.NewRequest(http.MethodPost, server.URL+path, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+
+		var result Response
+		_ = json.NewDecoder(resp.Body).Decode(&result)
+		return resp.StatusCode, &result
+	}
+
+	status, resp := post("/send/ops", "admin", "application/json", `{"msgtype":"text","content":"hello","mentions":["jayson"]}`)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, ErrcodeOK, resp.Code)
+	assert.Contains(t, received[0], `"mentioned_list":["jayson"]`)
+
+	form := url.Values{"msgtype": {"markdown"}, "content": {"**bold**"}}
+	status, _ = post("/send/dev", "ci", "application/x-www-form-urlencoded", form.Encode())
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, received[1], `"msgtype":"markdown"`)
+
+	status, _ = post("/send/ops", "", "application/json", `{"content":"hello"}`)
+	assert.Equal(t, http.StatusUnauthorized, status)
+	status, _ = post("/send/ops?token=admin", "", "application/json", `{"content":"hello"}`)
+	assert.Equal(t, http.StatusUnauthorized, status)
+	status, _ = post("/send/ops", "ci", "application/json", `{"content":"hello"}`)
+	assert.Equal(t, http.StatusForbidden, status)
+	status, _ = post("/send/missing", "admin", "application/json", `{"content":"hello"}`)
+	assert.Equal(t, http.StatusNotFound, status)
+	status, _ = post("/send/ops", "admin", "application/json", `{"msgtype":"news"}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, resp = post("/send/ops", "admin", "application/json", `{"content":"rejected"}`)
+	assert.Equal(t, http.StatusBadGateway, status)
+	assert.Equal(t, 40008, resp.Code)
+}
+
+func TestWithQueryToken(t *testing.T) {
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `{"errcode":0,"errmsg":"ok"}`)
+	}))
+	defer gateway.Close()
+
+	ops, _ := workrobot.NewClient("", workrobot.WithWebhook(gateway.URL))
+	server := New(map[string]*workrobot.Client{"ops": ops}, WithToken("admin", "*"), WithQueryToken())
+
+	req := httptest.NewRequest(http.MethodPost, "/send/ops?token=admin", strings.NewReader(`{"content":"hello"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestWithToken_Empty(t *testing.T) {
+	ops, _ := workrobot.NewClient("", workrobot.WithWebhook("http://127.0.0.1:1"))
+	server := New(map[string]*workrobot.Client{"ops": ops}, WithToken("", "*"), WithToken("admin", "ops"))
+	assert.Len(t, server.tokens, 1)
+
+	for _, auth := range []string{"", "Bearer ", "Bearer admin2", "Bearer admi"} {
+		req := httptest.NewRequest(http.MethodPost, "/send/ops", strings.NewReader(`{"content":"hello"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", auth)
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code, auth)
+	}
+}
@@ -0,0 +1,70 @@
+package workrobot
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"time"
+)
+
+// retryable errcodes of gateway
+// see https://work.weixin.qq.com/api/doc/90000/90139/90313
+const (
+	errcodeSystemBusy = -1
+	errcodeFreqLimit  = 45009
+)
+
+// retryPolicy represents the retry configuration of robot
+type retryPolicy struct {
+	attempts int
+	backoff  time.Duration
+}
+
+// wrap returns a handler resend the message when the attempt is retryable
+func (p *retryPolicy) wrap(next Handler) Handler {
+	return func(ctx context.Context, call *Call) error {
+		backoff := p.backoff
+		for {
+			err := next(ctx, call)
+			if err == nil || call.Attempt >= p.attempts || !retryable(err) {
+				return err
+			}
+
+			select {
+			case <-ctx.Done():
+				return err
+			case <-time.After(backoff):
+				backoff *= 2
+			}
+		}
+	}
+}
+
+// retryable checks whether the error is temporary, which is the
+// http request failed or gateway busy
+func retryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var receipt *Receipt
+	if errors.As(err, &receipt) {
+		return receipt.Code == errcodeSystemBusy || receipt.Code == errcodeFreqLimit
+	}
+
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+// WithRetry resend the message up to attempts times when the request failed or
+// gateway is busy, the backoff is doubled after each attempt
+func WithRetry(attempts int, backoff time.Duration) ClientOption {
+	return func(client *Client) error {
+		if attempts < 1 {
+			return errors.New("retry attempts must be positive")
+		}
+
+		client.retry = &retryPolicy{attempts: attempts, backoff: backoff}
+		return nil
+	}
+}
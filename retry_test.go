@@ -0,0 +1,74 @@
+package workrobot
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithRetry(t *testing.T) {
+	var sent int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent++
+		if sent < 3 {
+			_, _ = fmt.Fprint(w, `{"errcode":45009,"errmsg":"api freq out of limit"}`)
+		} else {
+			_, _ = fmt.Fprint(w, `{"errcode":0,"errmsg":"ok"}`)
+		}
+	}))
+	defer server.Close()
+
+	var attempts int
+	c, _ := NewClient("", WithWebhook(server.URL), WithRetry(3, time.Millisecond), WithInterceptor(
+		func(ctx context.Context, call *Call, next Handler) error {
+			err := next(ctx, call)
+			attempts = call.Attempt
+			return err
+		},
+	))
+
+	text, _ := NewText("hello")
+	assert.NoError(t, c.Send(text))
+	assert.Equal(t, 3, sent)
+	assert.Equal(t, 3, attempts)
+
+	_, err := NewClient("", WithRetry(0, time.Second))
+	assert.Error(t, err)
+}
+
+func TestWithRetry_NotRetryable(t *testing.T) {
+	var sent int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent++
+		_, _ = fmt.Fprint(w, `{"errcode":93000,"errmsg":"invalid webhook url"}`)
+	}))
+	defer server.Close()
+
+	c, _ := NewClient("", WithWebhook(server.URL), WithRetry(3, time.Millisecond))
+
+	text, _ := NewText("hello")
+	assert.Error(t, c.Send(text))
+	assert.Equal(t, 1, sent)
+}
+
+func TestWithRateLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `{"errcode":0,"errmsg":"ok"}`)
+	}))
+	defer server.Close()
+
+	c, _ := NewClient("", WithWebhook(server.URL), WithRateLimit(2, 100*time.Millisecond))
+
+	text, _ := NewText("hello")
+	start := time.Now()
+	assert.NoError(t, c.Send(text, text, text))
+	assert.GreaterOrEqual(t, int64(time.Since(start)), int64(40*time.Millisecond))
+
+	_, err := NewClient("", WithRateLimit(0, time.Minute))
+	assert.Error(t, err)
+}
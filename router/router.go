@@ -0,0 +1,160 @@
+// Package router routes messages to named robots by the labels from
+// workrobot.WithLabels, rules are evaluated in order like alertmanager.
+package router
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"regexp"
+
+	"github.com/wjiec/workrobot"
+
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+	"gopkg.in/yaml.v3"
+)
+
+// Route represents a rule routes the matched messages to robots
+type Route struct {
+	// Receivers are the names of robots messages sent to
+	Receivers []string `yaml:"receivers" json:"receivers"`
+	// Match selects messages have all the labels
+	Match map[string]string `yaml:"match,omitempty" json:"match,omitempty"`
+	// MatchRegexp selects messages with labels matched all the anchored regexps
+	MatchRegexp map[string]string `yaml:"match_re,omitempty" json:"match_re,omitempty"`
+	// Continue keeps evaluating the subsequent routes when matched
+	Continue bool `yaml:"continue,omitempty" json:"continue,omitempty"`
+
+	regexps map[string]*regexp.Regexp
+}
+
+// Matches checks whether the route selects the labels
+func (r *Route) Matches(labels workrobot.Labels) bool {
+	for k, v := range r.Match {
+		if labels[k] != v {
+			return false
+		}
+	}
+	for k, re := range r.regexps {
+		if !re.MatchString(labels[k]) {
+			return false
+		}
+	}
+	return true
+}
+
+// compile compiles the regexps of route
+func (r *Route) compile() error {
+	r.regexps = make(map[string]*regexp.Regexp, len(r.MatchRegexp))
+	for k, expr := range r.MatchRegexp {
+		re, err := regexp.Compile("^(?:" + expr + ")$")
+		if err != nil {
+			return errors.Wrapf(err, "invalid regexp of label %s", k)
+		}
+		r.regexps[k] = re
+	}
+	return nil
+}
+
+// Config represents the routes configuration
+type Config struct {
+	// Default is the receivers of messages not matched by any route
+	Default []string `yaml:"default" json:"default"`
+	Routes  []*Route `yaml:"routes" json:"routes"`
+}
+
+// ParseConfig parse routes configuration from yaml or json
+func ParseConfig(data []byte) (*Config, error) {
+	var config Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, errors.Wrap(err, "invalid routes config")
+	}
+	return &config, nil
+}
+
+// LoadConfig read routes configuration from reader
+func LoadConfig(reader io.Reader) (*Config, error) {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, errors.Wrap(err, "config unreadable")
+	}
+	return ParseConfig(data)
+}
+
+// Router sends messages to the robots of matched routes
+type Router struct {
+	config  *Config
+	clients map[string]*workrobot.Client
+}
+
+// Send send messages to the matched robots with the labels of context, all
+// robots are tried and errors are aggregated
+func (r *Router) Send(ctx context.Context, messages ...workrobot.Messager) (err error) {
+	for _, name := range r.Receivers(workrobot.LabelsFromContext(ctx)) {
+		if se := r.clients[name].SendContext(ctx, messages...); se != nil {
+			err = multierr.Append(err, errors.Wrapf(se, "send to %s", name))
+		}
+	}
+	return
+}
+
+// Routes returns the matched routes of labels in order, the default route
+// is returned when no route matched
+func (r *Router) Routes(labels workrobot.Labels) []*Route {
+	var matched []*Route
+	for _, route := range r.config.Routes {
+		if route.Matches(labels) {
+			matched = append(matched, route)
+			if !route.Continue {
+				break
+			}
+		}
+	}
+
+	if len(matched) == 0 {
+		return []*Route{{Receivers: r.config.Default}}
+	}
+	return matched
+}
+
+// Receivers returns the names of robots messages with labels would be sent to
+func (r *Router) Receivers(labels workrobot.Labels) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, route := range r.Routes(labels) {
+		for _, name := range route.Receivers {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// New create a router sends messages to the named clients by config
+func New(config *Config, clients map[string]*workrobot.Client) (*Router, error) {
+	check := func(receivers []string) error {
+		for _, name := range receivers {
+			if clients[name] == nil {
+				return errors.Errorf("unknown receiver %q", name)
+			}
+		}
+		return nil
+	}
+
+	if err := check(config.Default); err != nil {
+		return nil, errors.Wrap(err, "default route")
+	}
+	for i, route := range config.Routes {
+		if err := check(route.Receivers); err != nil {
+			return nil, errors.Wrapf(err, "route %d", i)
+		}
+		if err := route.compile(); err != nil {
+			return nil, errors.Wrapf(err, "route %d", i)
+		}
+	}
+
+	return &Router{config: config, clients: clients}, nil
+}
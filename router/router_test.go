@@ -0,0 +1,70 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/wjiec/workrobot"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const config = `
+default: [ops]
+routes:
+  - match: {severity: critical}
+    receivers: [oncall]
+    continue: true
+  - match: {team: payment}
+    match_re: {environment: "prod|staging"}
+    receivers: [payment, ops]
+  - match_re: {service: "api-.*"}
+    receivers: [backend]
+`
+
+func TestRouter(t *testing.T) {
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.URL.Path)
+		_, _ = fmt.Fprint(w, `{"errcode":0,"errmsg":"ok"}`)
+	}))
+	defer server.Close()
+
+	clients := map[string]*workrobot.Client{}
+	for _, name := range []string{"ops", "oncall", "payment", "backend"} {
+		clients[name], _ = workrobot.NewClient("", workrobot.WithWebhook(server.URL+"/"+name))
+	}
+
+	cfg, err := ParseConfig([]byte(config))
+	if !assert.NoError(t, err) {
+		return
+	}
+	r, err := New(cfg, clients)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, []string{"ops"}, r.Receivers(workrobot.Labels{"service": "web"}))
+	assert.Equal(t, []string{"backend"}, r.Receivers(workrobot.Labels{"service": "api-user"}))
+	assert.Equal(t, []string{"ops"}, r.Receivers(workrobot.Labels{"team": "payment", "environment": "dev"}))
+	assert.Equal(t, []string{"oncall", "payment", "ops"}, r.Receivers(workrobot.Labels{
+		"severity": "critical", "team": "payment", "environment": "prod", "service": "api-pay",
+	}))
+	assert.Len(t, r.Routes(workrobot.Labels{"severity": "critical"}), 1)
+
+	text, _ := workrobot.NewText("deploy failed")
+	ctx := workrobot.WithLabels(context.Background(), workrobot.Labels{"severity": "critical", "service": "api-user"})
+	assert.NoError(t, r.Send(ctx, text))
+	assert.Equal(t, []string{"/oncall", "/backend"}, received)
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(&Config{Default: []string{"missing"}}, nil)
+	assert.Error(t, err)
+
+	_, err = New(&Config{Routes: []*Route{{MatchRegexp: map[string]string{"service": "("}}}}, nil)
+	assert.Error(t, err)
+}
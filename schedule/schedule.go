@@ -0,0 +1,354 @@
+// Package schedule implements delayed and recurring messages of robot.
+package schedule
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/wjiec/workrobot"
+
+	"github.com/pkg/errors"
+	"github.com/robfig/cron/v3"
+	uuid "github.com/satori/go.uuid"
+)
+
+// min delay of retrying failed one-shot messages
+const minRetryDelay = time.Second
+
+var (
+	// ErrNotFound represents the schedule not exists or already finished
+	ErrNotFound = errors.New("schedule not found")
+)
+
+// Clock represents the source of time, it can be replaced in tests
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer represents a timer of clock, like time.Timer
+type Timer interface {
+	// C returns the channel the time delivered on when the timer fires
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// SystemClock represents the clock of system
+var SystemClock Clock = realClock{}
+
+// realClock represents the clock of system
+type realClock struct{}
+
+// Now returns the current local time
+func (realClock) Now() time.Time { return time.Now() }
+
+// NewTimer create a timer fires after the duration
+func (realClock) NewTimer(d time.Duration) Timer { return realTimer{time.NewTimer(d)} }
+
+// realTimer represents a timer of system
+type realTimer struct {
+	*time.Timer
+}
+
+// C returns the channel of timer
+func (t realTimer) C() <-chan time.Time { return t.Timer.C }
+
+// Entry represents a scheduled message
+type Entry struct {
+	Id string `json:"id"`
+	// Spec is the cron expression of recurring message, empty for one-shot message
+	Spec string `json:"spec,omitempty"`
+	// Next is the time of message to be sent
+	Next time.Time `json:"next"`
+	// Payload is the request payload of message
+	Payload []byte `json:"payload"`
+
+	schedule cron.Schedule
+}
+
+// Message implement Messager and returns the payload of scheduled message
+func (e *Entry) Message() []byte {
+	return e.Payload
+}
+
+// Scheduler sends the scheduled messages through the client
+type Scheduler struct {
+	client *workrobot.Client
+	clock  Clock
+	store  Store
+	parser cron.Parser
+
+	mu      sync.Mutex
+	entries map[string]*Entry
+	wake    chan struct{}
+
+	retryDelay time.Duration
+	onError    func(entry *Entry, err error)
+}
+
+// At schedule the message to be sent at the time
+func (s *Scheduler) At(at time.Time, msg workrobot.Messager) (string, error) {
+	return s.add(&Entry{Next: at, Payload: msg.Message()})
+}
+
+// After schedule the message to be sent after the duration
+func (s *Scheduler) After(d time.Duration, msg workrobot.Messager) (string, error) {
+	return s.At(s.clock.Now().Add(d), msg)
+}
+
+// Every schedule the message to be sent recurring by the cron expression, which
+// supports standard 5 fields and descriptors like @daily, @every 1h
+func (s *Scheduler) Every(spec string, msg workrobot.Messager) (string, error) {
+	schedule, err := s.parser.Parse(spec)
+	if err != nil {
+		return "", errors.Wrap(err, "invalid cron expression")
+	}
+
+	return s.add(&Entry{Spec: spec, Next: schedule.Next(s.clock.Now()), Payload: msg.Message(), schedule: schedule})
+}
+
+// Cancel removes the scheduled message by id, the message being dispatched
+// is not sent unless the sending already started
+func (s *Scheduler) Cancel(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, found := s.entries[id]; !found {
+		return ErrNotFound
+	}
+
+	delete(s.entries, id)
+	s.notify()
+	return s.persist()
+}
+
+// Entries returns all scheduled messages ordered by the next time
+func (s *Scheduler) Entries() []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := make([]Entry, 0, len(s.entries))
+	for _, entry := range s.entries {
+		entries = append(entries, *entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Next.Before(entries[j].Next) })
+
+	return entries
+}
+
+// Run sends the messages when they are due until the context done
+func (s *Scheduler) Run(ctx context.Context) error {
+	timer := s.clock.NewTimer(time.Hour)
+	defer timer.Stop()
+
+	for {
+		if !timer.Stop() {
+			select { // drain the fired but not received time
+			case <-timer.C():
+			default:
+			}
+		}
+
+		var fired <-chan time.Time
+		if next, found := s.next(); found {
+			timer.Reset(next.Sub(s.clock.Now()))
+			fired = timer.C()
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.wake:
+		case <-fired:
+			s.dispatch(ctx)
+		}
+	}
+}
+
+// add append an entry and wake up the runner
+func (s *Scheduler) add(entry *Entry) (string, error) {
+	entry.Id = uuid.NewV4().String()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries[entry.Id] = entry
+	s.notify()
+	return entry.Id, s.persist()
+}
+
+// next returns the time of the earliest entry
+func (s *Scheduler) next() (next time.Time, found bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, entry := range s.entries {
+		if !found || entry.Next.Before(next) {
+			next, found = entry.Next, true
+		}
+	}
+	return
+}
+
+// dispatch sends all due messages and reschedule the recurring ones, the
+// one-shot messages are removed after sent, and retried later when failed
+func (s *Scheduler) dispatch(ctx context.Context) {
+	now := s.clock.Now()
+
+	s.mu.Lock()
+	var due []*Entry
+	for id, entry := range s.entries {
+		if entry.Next.After(now) {
+			continue
+		}
+
+		due = append(due, entry)
+		copied := *entry
+		if entry.schedule == nil {
+			copied.Next = now.Add(s.retryDelay)
+		} else {
+			copied.Next = entry.schedule.Next(now)
+		}
+		s.entries[id] = &copied
+	}
+	err := s.persist()
+	s.mu.Unlock()
+
+	if err != nil && s.onError != nil {
+		s.onError(nil, err)
+	}
+
+	sort.Slice(due, func(i, j int) bool { return due[i].Next.Before(due[j].Next) })
+	for _, entry := range due {
+		if s.cancelled(entry.Id) {
+			continue
+		}
+
+		if err := s.client.SendContext(ctx, entry); err != nil {
+			if s.onError != nil {
+				s.onError(entry, err)
+			}
+			continue
+		}
+
+		if entry.schedule == nil {
+			if err := s.finish(entry.Id); err != nil && s.onError != nil {
+				s.onError(nil, err)
+			}
+		}
+	}
+}
+
+// cancelled checks whether the entry is cancelled during dispatching
+func (s *Scheduler) cancelled(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, found := s.entries[id]
+	return !found
+}
+
+// finish removes the sent one-shot entry
+func (s *Scheduler) finish(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, found := s.entries[id]; !found {
+		return nil
+	}
+
+	delete(s.entries, id)
+	return s.persist()
+}
+
+// notify wake up the runner to recalculate the next time
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// persist saves the entries into store, must be called with lock held
+func (s *Scheduler) persist() error {
+	if s.store == nil {
+		return nil
+	}
+
+	entries := make([]*Entry, 0, len(s.entries))
+	for _, entry := range s.entries {
+		entries = append(entries, entry)
+	}
+	return s.store.Save(entries)
+}
+
+// Option represents additional scheduler configuration
+type Option func(*Scheduler)
+
+// WithClock override the clock of scheduler
+func WithClock(clock Clock) Option {
+	return func(s *Scheduler) {
+		s.clock = clock
+	}
+}
+
+// WithStore persist the schedules into the store, and restore them when created
+func WithStore(store Store) Option {
+	return func(s *Scheduler) {
+		s.store = store
+	}
+}
+
+// WithRetryDelay sets the delay of retrying one-shot messages failed to
+// send, defaults to 1 minute, and delays less than 1 second are ignored
+func WithRetryDelay(delay time.Duration) Option {
+	return func(s *Scheduler) {
+		if delay >= minRetryDelay {
+			s.retryDelay = delay
+		}
+	}
+}
+
+// WithErrorHandler sets the handler of errors when sending messages, the
+// entry is nil when error occurs on persisting
+func WithErrorHandler(fn func(entry *Entry, err error)) Option {
+	return func(s *Scheduler) {
+		s.onError = fn
+	}
+}
+
+// New create a scheduler sends messages through the client
+func New(client *workrobot.Client, options ...Option) (*Scheduler, error) {
+	s := &Scheduler{
+		client:     client,
+		clock:      SystemClock,
+		parser:     cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor),
+		entries:    make(map[string]*Entry),
+		wake:       make(chan struct{}, 1),
+		retryDelay: time.Minute,
+	}
+	for _, opt := range options {
+		opt(s)
+	}
+
+	if s.store != nil {
+		entries, err := s.store.Load()
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot load schedules")
+		}
+
+		for _, entry := range entries {
+			if entry.Spec != "" {
+				if entry.schedule, err = s.parser.Parse(entry.Spec); err != nil {
+					return nil, errors.Wrapf(err, "invalid cron expression of %s", entry.Id)
+				}
+			}
+			s.entries[entry.Id] = entry
+		}
+	}
+
+	return s, nil
+}
@@ -0,0 +1,272 @@
+package schedule
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/wjiec/workrobot"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) NewTimer(d time.Duration) Timer {
+	t := &fakeTimer{clock: c, ch: make(chan time.Time, 1)}
+	c.mu.Lock()
+	c.timers = append(c.timers, t)
+	c.mu.Unlock()
+
+	t.Reset(d)
+	return t
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+	for _, t := range c.timers {
+		t.fire()
+	}
+}
+
+func (c *fakeClock) count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+type fakeTimer struct {
+	clock    *fakeClock
+	ch       chan time.Time
+	deadline time.Time
+	active   bool
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.ch
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	active := t.active
+	t.active = false
+	return active
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	active := t.active
+	t.deadline, t.active = t.clock.now.Add(d), true
+	t.fire()
+	return active
+}
+
+// fire sends the time when the timer is due, must be called with lock held
+func (t *fakeTimer) fire() {
+	if t.active && !t.deadline.After(t.clock.now) {
+		t.active = false
+		select {
+		case t.ch <- t.clock.now:
+		default:
+		}
+	}
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2021, 6, 1, 8, 0, 0, 0, time.Local)}
+}
+
+type recorder struct {
+	mu       sync.Mutex
+	received []string
+}
+
+func (r *recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	bs, _ := ioutil.ReadAll(req.Body)
+	r.mu.Lock()
+	r.received = append(r.received, string(bs))
+	r.mu.Unlock()
+	_, _ = fmt.Fprint(w, `{"errcode":0,"errmsg":"ok"}`)
+}
+
+func (r *recorder) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.received)
+}
+
+func TestScheduler(t *testing.T) {
+	rec := &recorder{}
+	server := httptest.NewServer(rec)
+	defer server.Close()
+
+	clock := newFakeClock()
+	client, _ := workrobot.NewClient("", workrobot.WithWebhook(server.URL))
+	s, err := New(client, WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = s.Run(ctx) }()
+
+	standup, _ := workrobot.NewText("standup")
+	_, err = s.Every("30 9 * * 1-5", standup)
+	assert.NoError(t, err)
+
+	later, _ := workrobot.NewText("later")
+	_, err = s.After(time.Hour, later)
+	assert.NoError(t, err)
+
+	cancelled, _ := workrobot.NewText("cancelled")
+	id, _ := s.After(time.Hour, cancelled)
+	assert.NoError(t, s.Cancel(id))
+	assert.ErrorIs(t, s.Cancel(id), ErrNotFound)
+
+	_, err = s.Every("invalid", standup)
+	assert.Error(t, err)
+
+	clock.Advance(time.Hour)
+	assert.Eventually(t, func() bool { return rec.count() == 1 }, time.Second, time.Millisecond)
+
+	clock.Advance(30 * time.Minute)
+	assert.Eventually(t, func() bool { return rec.count() == 2 }, time.Second, time.Millisecond)
+
+	entries := s.Entries()
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, time.Date(2021, 6, 2, 9, 30, 0, 0, time.Local), entries[0].Next)
+	}
+	assert.Equal(t, 1, clock.count())
+	assert.Contains(t, rec.received[0], "later")
+	assert.Contains(t, rec.received[1], "standup")
+}
+
+func TestScheduler_Retry(t *testing.T) {
+	var failed int32
+	rec := &recorder{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&failed, 1) == 1 {
+			_, _ = fmt.Fprint(w, `{"errcode":45009,"errmsg":"api freq out of limit"}`)
+			return
+		}
+		rec.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	errs := make(chan error, 1)
+	clock := newFakeClock()
+	client, _ := workrobot.NewClient("", workrobot.WithWebhook(server.URL))
+	s, _ := New(client, WithClock(clock), WithRetryDelay(time.Minute),
+		WithErrorHandler(func(entry *Entry, err error) { errs <- err }))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = s.Run(ctx) }()
+
+	text, _ := workrobot.NewText("retry")
+	_, _ = s.After(time.Hour, text)
+
+	clock.Advance(time.Hour)
+	assert.Error(t, <-errs)
+	if entries := s.Entries(); assert.Len(t, entries, 1) {
+		assert.Equal(t, clock.Now().Add(time.Minute), entries[0].Next)
+	}
+
+	clock.Advance(time.Minute)
+	assert.Eventually(t, func() bool { return rec.count() == 1 && len(s.Entries()) == 0 }, time.Second, time.Millisecond)
+}
+
+func TestWithRetryDelay(t *testing.T) {
+	s, _ := New(nil, WithRetryDelay(0))
+	assert.Equal(t, time.Minute, s.retryDelay)
+
+	s, _ = New(nil, WithRetryDelay(-time.Second))
+	assert.Equal(t, time.Minute, s.retryDelay)
+
+	s, _ = New(nil, WithRetryDelay(time.Second))
+	assert.Equal(t, time.Second, s.retryDelay)
+}
+
+func TestScheduler_CancelDispatching(t *testing.T) {
+	arrived, release := make(chan struct{}), make(chan struct{})
+	rec := &recorder{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rec.count() == 0 {
+			close(arrived)
+			<-release
+		}
+		rec.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	clock := newFakeClock()
+	client, _ := workrobot.NewClient("", workrobot.WithWebhook(server.URL))
+	s, _ := New(client, WithClock(clock))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = s.Run(ctx) }()
+
+	first, _ := workrobot.NewText("first")
+	_, _ = s.After(time.Hour, first)
+	second, _ := workrobot.NewText("second")
+	id, _ := s.After(time.Hour+time.Second, second)
+
+	clock.Advance(2 * time.Hour)
+	<-arrived
+	assert.NoError(t, s.Cancel(id))
+	close(release)
+
+	assert.Eventually(t, func() bool { return len(s.Entries()) == 0 }, time.Second, time.Millisecond)
+	assert.Equal(t, 1, rec.count())
+}
+
+func TestFileStore(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "schedule")
+	defer func() { _ = os.RemoveAll(dir) }()
+	store := NewFileStore(filepath.Join(dir, "schedules.json"))
+
+	clock := newFakeClock()
+	client, _ := workrobot.NewClient("")
+	s, _ := New(client, WithClock(clock), WithStore(store))
+
+	text, _ := workrobot.NewText("weekly report")
+	id, err := s.Every("@weekly", text)
+	assert.NoError(t, err)
+
+	restored, err := New(client, WithClock(clock), WithStore(store))
+	if assert.NoError(t, err) {
+		entries := restored.Entries()
+		if assert.Len(t, entries, 1) {
+			assert.Equal(t, id, entries[0].Id)
+			assert.Equal(t, text.Message(), entries[0].Payload)
+			assert.NotNil(t, entries[0].schedule)
+		}
+	}
+}
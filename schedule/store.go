@@ -0,0 +1,67 @@
+package schedule
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// Store represents the storage of scheduled messages
+type Store interface {
+	Load() ([]*Entry, error)
+	Save(entries []*Entry) error
+}
+
+// FileStore represents a store saves schedules as json file
+type FileStore struct {
+	filename string
+}
+
+// Load read schedules from the file, returns empty when file not exists
+func (fs *FileStore) Load() ([]*Entry, error) {
+	bs, err := ioutil.ReadFile(fs.filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "cannot read file")
+	}
+
+	var entries []*Entry
+	if err := json.Unmarshal(bs, &entries); err != nil {
+		return nil, errors.Wrap(err, "invalid schedules file")
+	}
+	return entries, nil
+}
+
+// Save write schedules into the file atomically
+func (fs *FileStore) Save(entries []*Entry) error {
+	bs, err := json.Marshal(entries)
+	if err != nil {
+		return errors.Wrap(err, "cannot encode schedules")
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(fs.filename), filepath.Base(fs.filename)+".*")
+	if err != nil {
+		return errors.Wrap(err, "cannot create file")
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(bs); err != nil {
+		_ = tmp.Close()
+		return errors.Wrap(err, "file not writable")
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrap(err, "file not writable")
+	}
+
+	return os.Rename(tmp.Name(), fs.filename)
+}
+
+// NewFileStore create a store saves schedules into filename
+func NewFileStore(filename string) *FileStore {
+	return &FileStore{filename: filename}
+}
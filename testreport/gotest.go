@@ -0,0 +1,89 @@
+package testreport
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// testEvent represents an event of `go test -json`
+//
+// see https://pkg.go.dev/cmd/test2json
+type testEvent struct {
+	Action     string  `json:"Action"`
+	Package    string  `json:"Package"`
+	ImportPath string  `json:"ImportPath"`
+	Test       string  `json:"Test"`
+	Elapsed    float64 `json:"Elapsed"`
+	Output     string  `json:"Output"`
+}
+
+// ParseGoTest parses the event stream of `go test -json`, lines not in json
+// are ignored, and packages failed without failed tests, like build errors,
+// are reported as failed results without name
+func ParseGoTest(r io.Reader) (*Summary, error) {
+	type key struct{ pkg, test string }
+
+	summary := &Summary{}
+	results := make(map[key]*Result)
+	outputs := make(map[key]*strings.Builder)
+	failedTests := make(map[string]bool)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 || line[0] != '{' {
+			continue
+		}
+
+		var event testEvent
+		if err := json.Unmarshal(line, &event); err != nil {
+			return nil, errors.Wrap(err, "invalid test event")
+		}
+
+		k := key{pkg: event.Package, test: event.Test}
+		if event.Action == "build-output" {
+			k.pkg = event.ImportPath
+			if i := strings.IndexByte(k.pkg, ' '); i >= 0 { // pkg [pkg.test]
+				k.pkg = k.pkg[:i]
+			}
+		}
+
+		switch event.Action {
+		case "output", "build-output":
+			if outputs[k] == nil {
+				outputs[k] = &strings.Builder{}
+			}
+			outputs[k].WriteString(event.Output)
+		case StatusPass, StatusFail, StatusSkip:
+			elapsed := time.Duration(event.Elapsed * float64(time.Second))
+			if event.Test == "" {
+				summary.Elapsed += elapsed
+				if event.Action != StatusFail || failedTests[event.Package] {
+					continue
+				}
+			} else if event.Action == StatusFail {
+				failedTests[event.Package] = true
+			}
+
+			result := &Result{Package: event.Package, Name: event.Test, Status: event.Action, Elapsed: elapsed}
+			results[k] = result
+			summary.Results = append(summary.Results, result)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "test events unreadable")
+	}
+
+	for k, result := range results {
+		if output := outputs[k]; output != nil {
+			result.Output = output.String()
+		}
+	}
+	return summary, nil
+}
@@ -0,0 +1,95 @@
+package testreport
+
+import (
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wjiec/workrobot/internal/strutil"
+
+	"github.com/pkg/errors"
+)
+
+// junitMessage represents the failure, error or skipped element
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Content string `xml:",chardata"`
+}
+
+// junitCase represents a testcase element
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure"`
+	Error     *junitMessage `xml:"error"`
+	Skipped   *junitMessage `xml:"skipped"`
+	SystemOut string        `xml:"system-out"`
+	SystemErr string        `xml:"system-err"`
+}
+
+// junitSuite represents a testsuite element, suites can be nested
+type junitSuite struct {
+	Name   string       `xml:"name,attr"`
+	Time   string       `xml:"time,attr"`
+	Suites []junitSuite `xml:"testsuite"`
+	Cases  []junitCase  `xml:"testcase"`
+}
+
+// ParseJUnit parses the JUnit XML report, the root element is either
+// testsuites or testsuite, the elapsed time is the sum of leaf suites
+func ParseJUnit(r io.Reader) (*Summary, error) {
+	var root junitSuite
+	if err := xml.NewDecoder(r).Decode(&root); err != nil {
+		return nil, errors.Wrap(err, "invalid junit report")
+	}
+
+	summary := &Summary{}
+	var walk func(suite *junitSuite)
+	walk = func(suite *junitSuite) {
+		// the time of parent suites includes their children
+		if len(suite.Suites) == 0 {
+			summary.Elapsed += seconds(suite.Time)
+		}
+
+		for _, c := range suite.Cases {
+			result := &Result{Package: strutil.FirstOf(c.ClassName, suite.Name), Name: c.Name,
+				Status: StatusPass, Elapsed: seconds(c.Time)}
+			switch {
+			case c.Failure != nil:
+				result.Status, result.Output = StatusFail, output(c.Failure, c.SystemOut, c.SystemErr)
+			case c.Error != nil:
+				result.Status, result.Output = StatusFail, output(c.Error, c.SystemOut, c.SystemErr)
+			case c.Skipped != nil:
+				result.Status, result.Output = StatusSkip, output(c.Skipped, "", "")
+			}
+			summary.Results = append(summary.Results, result)
+		}
+		for i := range suite.Suites {
+			walk(&suite.Suites[i])
+		}
+	}
+	walk(&root)
+
+	return summary, nil
+}
+
+// output returns the message and outputs of test case
+func output(m *junitMessage, stdout, stderr string) string {
+	var parts []string
+	for _, part := range []string{m.Message, m.Content, stdout, stderr} {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// seconds parses the time attribute in seconds
+func seconds(s string) time.Duration {
+	f, _ := strconv.ParseFloat(strings.ReplaceAll(s, ",", ""), 64)
+	return time.Duration(f * float64(time.Second))
+}
@@ -0,0 +1,248 @@
+// Package testreport parses the results of `go test -json` and JUnit XML
+// reports, and sends the markdown summary to robot with the full failure
+// log uploaded as a file.
+package testreport
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/wjiec/workrobot"
+	md "github.com/wjiec/workrobot/markdown"
+
+	"github.com/pkg/errors"
+)
+
+// status of test result
+const (
+	StatusPass = "pass"
+	StatusFail = "fail"
+	StatusSkip = "skip"
+)
+
+// max length of failure output in summary
+const maxOutputLength = 300
+
+// Result represents the result of a test case
+type Result struct {
+	Package string
+	Name    string
+	Status  string
+	Elapsed time.Duration
+	Output  string
+}
+
+// FullName returns the package qualified name of test
+func (r *Result) FullName() string {
+	if r.Name == "" {
+		return r.Package
+	}
+	if r.Package == "" {
+		return r.Name
+	}
+	return r.Package + "." + r.Name
+}
+
+// Summary represents the results of a test run
+type Summary struct {
+	Results []*Result
+	Elapsed time.Duration
+}
+
+// Count returns the count of results with the status
+func (s *Summary) Count(status string) (n int) {
+	for _, r := range s.Results {
+		if r.Status == status {
+			n++
+		}
+	}
+	return
+}
+
+// Failed returns the failed results
+func (s *Summary) Failed() (results []*Result) {
+	for _, r := range s.Results {
+		if r.Status == StatusFail {
+			results = append(results, r)
+		}
+	}
+	return
+}
+
+// Slowest returns the n slowest results
+func (s *Summary) Slowest(n int) []*Result {
+	results := append([]*Result(nil), s.Results...)
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Elapsed > results[j].Elapsed
+	})
+
+	if len(results) > n {
+		results = results[:n]
+	}
+	return results
+}
+
+// Markdown renders the summary as markdown, the failed tests are listed with
+// the tail of output until the message is full
+func (s *Summary) Markdown(title string, slowest int) (*workrobot.Markdown, error) {
+	failed := s.Failed()
+	status := md.ColorGreen("PASS")
+	if len(failed) != 0 {
+		status = md.ColorOrangeRed("FAIL")
+	}
+
+	header := md.Join(" ", md.Bold(status), title)
+	counts := fmt.Sprintf("%d passed, %d failed, %d skipped", s.Count(StatusPass), len(failed), s.Count(StatusSkip))
+	if s.Elapsed != 0 {
+		counts += " in " + s.Elapsed.Round(time.Millisecond).String()
+	}
+
+	lines := []string{strings.TrimSpace(header.String()), md.Quote(counts).String()}
+	if slowest > 0 && len(s.Results) != 0 {
+		lines = append(lines, md.Bold("Slowest").String())
+		for _, r := range s.Slowest(slowest) {
+			lines = append(lines, md.Join(" ", md.Code(r.Elapsed.Round(time.Millisecond).String()), r.FullName()).String())
+		}
+	}
+
+	if len(failed) != 0 {
+		lines = append(lines, md.Bold("Failed").String())
+	}
+
+	size := len(strings.Join(lines, "\n"))
+	for i, r := range failed {
+		entry := md.ColorOrangeRed(r.FullName()).String()
+		if output := tail(r.Output, maxOutputLength); output != "" {
+			entry += "\n" + md.Quote(output).String()
+		}
+
+		more := md.ColorGray(fmt.Sprintf("... and %d more", len(failed)-i)).String()
+		if size+len(entry)+len(more)+2 > workrobot.MarkdownMessageMaxLength {
+			lines = append(lines, more)
+			break
+		}
+
+		size += len(entry) + 1
+		lines = append(lines, entry)
+	}
+
+	var msg workrobot.Markdown
+	return &msg, msg.RawContent(strings.Join(lines, "\n"))
+}
+
+// FailureLog returns the full output of failed tests
+func (s *Summary) FailureLog() []byte {
+	var buf bytes.Buffer
+	for _, r := range s.Failed() {
+		_, _ = fmt.Fprintf(&buf, "=== FAIL: %s (%s)\n%s\n", r.FullName(), r.Elapsed, strings.TrimRight(r.Output, "\n"))
+	}
+	return buf.Bytes()
+}
+
+// tail returns the last n bytes of trimmed output in whole lines
+func tail(output string, n int) string {
+	output = strings.TrimSpace(output)
+	if len(output) <= n {
+		return output
+	}
+
+	output = output[len(output)-n:]
+	if i := strings.IndexByte(output, '\n'); i >= 0 {
+		output = output[i+1:]
+	}
+	return "..." + "\n" + strings.ToValidUTF8(output, "")
+}
+
+// Parse parses the go test json stream or JUnit XML report by its content
+func Parse(r io.Reader) (*Summary, error) {
+	br := bufio.NewReader(r)
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			if err == io.EOF {
+				return &Summary{}, nil
+			}
+			return nil, errors.Wrap(err, "report unreadable")
+		}
+
+		if b == ' ' || b == '\t' || b == '\r' || b == '\n' {
+			continue
+		}
+		_ = br.UnreadByte()
+
+		if b == '<' {
+			return ParseJUnit(br)
+		}
+		return ParseGoTest(br)
+	}
+}
+
+// Reporter represents a reporter sends test summaries to robot
+type Reporter struct {
+	client    *workrobot.Client
+	title     string
+	slowest   int
+	uploadLog bool
+}
+
+// Send sends the summary to robot, and uploads the failure log as a file
+// when there are failed tests
+func (r *Reporter) Send(ctx context.Context, s *Summary) error {
+	msg, err := s.Markdown(r.title, r.slowest)
+	if err != nil {
+		return err
+	}
+	if err := r.client.SendContext(ctx, msg); err != nil {
+		return err
+	}
+
+	log := s.FailureLog()
+	if len(log) == 0 || !r.uploadLog {
+		return nil
+	}
+
+	media, err := r.client.Uploader().UploadFromBytesContext(ctx, "test-failures.log", log)
+	if err != nil {
+		return err
+	}
+	return r.client.SendContext(ctx, workrobot.NewMedia(media.Id))
+}
+
+// Option represents additional reporter configuration
+type Option func(*Reporter)
+
+// WithTitle sets the title of summary, like the name of CI job
+func WithTitle(title string) Option {
+	return func(r *Reporter) {
+		r.title = title
+	}
+}
+
+// WithSlowest lists the n slowest tests in summary, defaults to 5
+func WithSlowest(n int) Option {
+	return func(r *Reporter) {
+		r.slowest = n
+	}
+}
+
+// WithUploadLog uploads the failure log as a file, defaults to true
+func WithUploadLog(upload bool) Option {
+	return func(r *Reporter) {
+		r.uploadLog = upload
+	}
+}
+
+// New create a reporter sends test summaries to client
+func New(client *workrobot.Client, options ...Option) *Reporter {
+	r := &Reporter{client: client, slowest: 5, uploadLog: true}
+	for _, opt := range options {
+		opt(r)
+	}
+	return r
+}
@@ -0,0 +1,120 @@
+package testreport
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wjiec/workrobot"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func parseFile(t *testing.T, filename string) *Summary {
+	f, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+
+	s, err := Parse(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestParseGoTest(t *testing.T) {
+	s := parseFile(t, "testdata/gotest.json")
+	assert.Equal(t, 1, s.Count(StatusPass))
+	assert.Equal(t, 1, s.Count(StatusSkip))
+	assert.Equal(t, 1700*time.Millisecond, s.Elapsed)
+
+	failed := s.Failed()
+	if assert.Len(t, failed, 2) {
+		assert.Equal(t, "example.com/app.TestSub", failed[0].FullName())
+		assert.Contains(t, failed[0].Output, "expected 1, got 2")
+		assert.Equal(t, "example.com/broken", failed[1].FullName())
+		assert.Contains(t, failed[1].Output, "syntax error")
+	}
+	assert.Equal(t, "TestSub", s.Slowest(1)[0].Name)
+}
+
+func TestParseJUnit(t *testing.T) {
+	s := parseFile(t, "testdata/junit.xml")
+	assert.Equal(t, 1, s.Count(StatusPass))
+	assert.Equal(t, 1, s.Count(StatusSkip))
+	assert.Equal(t, 2500*time.Millisecond, s.Elapsed)
+
+	if failed := s.Failed(); assert.Len(t, failed, 1) {
+		assert.Equal(t, "com.example.CalculatorTest.testSub", failed[0].FullName())
+		assert.Equal(t, "expected 1 but was 2\nat CalculatorTest.java:12", failed[0].Output)
+	}
+
+	s, err := Parse(strings.NewReader(`<testsuite name="single"><testcase name="a" time="1"/></testsuite>`))
+	if assert.NoError(t, err) && assert.Len(t, s.Results, 1) {
+		assert.Equal(t, "single.a", s.Results[0].FullName())
+	}
+
+	s, err = Parse(strings.NewReader(`<testsuites time="3"><testsuite name="parent" time="3">
+		<testsuite name="child1" time="1"><testcase name="a" time="1"/></testsuite>
+		<testsuite name="child2" time="2"><testcase name="b" time="2"/></testsuite>
+	</testsuite></testsuites>`))
+	if assert.NoError(t, err) {
+		assert.Len(t, s.Results, 2)
+		assert.Equal(t, 3*time.Second, s.Elapsed)
+	}
+}
+
+func TestSummary_Markdown(t *testing.T) {
+	s := parseFile(t, "testdata/gotest.json")
+	msg, err := s.Markdown("ci #42", 2)
+	if assert.NoError(t, err) {
+		content := string(msg.Message())
+		assert.Contains(t, content, "FAIL")
+		assert.Contains(t, content, "1 passed, 2 failed, 1 skipped in 1.7s")
+		assert.Contains(t, content, "`1.5s` example.com/app.TestSub")
+		assert.Contains(t, content, "expected 1, got 2")
+	}
+
+	for i := 0; i < 100; i++ {
+		s.Results = append(s.Results, &Result{Name: fmt.Sprintf("Test%d", i), Status: StatusFail, Output: strings.Repeat("x\n", 200)})
+	}
+	msg, err = s.Markdown("", 0)
+	if assert.NoError(t, err) {
+		assert.Contains(t, string(msg.Message()), "more")
+	}
+}
+
+func TestReporter_Send(t *testing.T) {
+	var received []string
+	var uploaded string
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bs, _ := ioutil.ReadAll(r.Body)
+		if strings.Contains(r.URL.Path, "upload_media") {
+			uploaded = string(bs)
+			_, _ = fmt.Fprint(w, `{"errcode":0,"errmsg":"ok","type":"file","media_id":"media-1","created_at":"1380000000"}`)
+			return
+		}
+
+		received = append(received, string(bs))
+		_, _ = fmt.Fprint(w, `{"errcode":0,"errmsg":"ok"}`)
+	}))
+	defer gateway.Close()
+
+	client, _ := workrobot.NewClient("key", workrobot.WithWebhook(gateway.URL+"/send"),
+		workrobot.WithUploadGateway(gateway.URL+"/upload_media"))
+
+	assert.NoError(t, New(client).Send(context.Background(), parseFile(t, "testdata/gotest.json")))
+	if assert.Len(t, received, 2) {
+		assert.Contains(t, received[1], `"media_id":"media-1"`)
+		assert.Contains(t, uploaded, `filename="test-failures.log"`)
+		assert.Contains(t, uploaded, "=== FAIL: example.com/app.TestSub")
+	}
+}
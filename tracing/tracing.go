@@ -0,0 +1,141 @@
+// Package tracing instruments robot clients and uploaders with opentelemetry
+// spans, each send and upload creates a span from the caller context.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/url"
+
+	"github.com/wjiec/workrobot"
+	"github.com/wjiec/workrobot/media"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// instrumentation name of the tracer
+	InstrumentationName = "github.com/wjiec/workrobot/tracing"
+)
+
+const (
+	AttributeMessageType = attribute.Key("workrobot.message.type")
+	AttributeMessageSize = attribute.Key("workrobot.message.size")
+	AttributeUploadName  = attribute.Key("workrobot.upload.filename")
+	AttributeUploadSize  = attribute.Key("workrobot.upload.size")
+	AttributeErrcode     = attribute.Key("workrobot.errcode")
+	AttributeAttempt     = attribute.Key("workrobot.attempt")
+	AttributeWebhook     = attribute.Key("workrobot.webhook")
+)
+
+// Tracer represents an opentelemetry tracer for robot clients
+type Tracer struct {
+	provider trace.TracerProvider
+	tracer   trace.Tracer
+}
+
+// Interceptor returns an interceptor that creates a span for each message delivery
+func (t *Tracer) Interceptor() workrobot.Interceptor {
+	return func(ctx context.Context, call *workrobot.Call, next workrobot.Handler) error {
+		ctx, span := t.tracer.Start(ctx, "workrobot.send", trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				AttributeMessageType.String(call.Type()),
+				AttributeMessageSize.Int(len(call.Payload())),
+				AttributeWebhook.String(Redact(call.Webhook)),
+			))
+		defer span.End()
+
+		err := next(ctx, call)
+		span.SetAttributes(AttributeAttempt.Int(call.Attempt))
+		if call.Receipt != nil {
+			span.SetAttributes(AttributeErrcode.Int(call.Receipt.Code))
+		}
+
+		record(span, err)
+		return err
+	}
+}
+
+// UploadInterceptor returns an interceptor that creates a span for each media upload
+func (t *Tracer) UploadInterceptor() media.Interceptor {
+	return func(ctx context.Context, up *media.Upload, next media.Handler) error {
+		ctx, span := t.tracer.Start(ctx, "workrobot.upload", trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				AttributeUploadName.String(up.Filename),
+				AttributeUploadSize.Int64(up.Size),
+				AttributeWebhook.String(Redact(up.Endpoint)),
+			))
+		defer span.End()
+
+		err := next(ctx, up)
+		span.SetAttributes(AttributeAttempt.Int(up.Attempt))
+
+		var receipt *media.Receipt
+		if errors.As(err, &receipt) {
+			span.SetAttributes(AttributeErrcode.Int(receipt.Code))
+		}
+
+		record(span, err)
+		return err
+	}
+}
+
+// Instrument returns a client option that registers both the send
+// and upload interceptors of tracer on the robot
+func (t *Tracer) Instrument() workrobot.ClientOption {
+	return func(client *workrobot.Client) error {
+		if err := workrobot.WithInterceptor(t.Interceptor())(client); err != nil {
+			return err
+		}
+		return workrobot.WithUploadInterceptor(t.UploadInterceptor())(client)
+	}
+}
+
+// record mark the span as failed when error occurs
+func record(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
+
+// Redact hides the robot key in webhook address
+func Redact(webhook string) string {
+	api, err := url.Parse(webhook)
+	if err != nil {
+		return ""
+	}
+
+	q := api.Query()
+	if q.Get("key") != "" {
+		q.Set("key", "REDACTED")
+	}
+
+	api.RawQuery = q.Encode()
+	return api.String()
+}
+
+// Option represents additional tracer configuration
+type Option func(*Tracer)
+
+// WithTracerProvider override the tracer provider, the global
+// provider is used by default
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(t *Tracer) {
+		t.provider = provider
+	}
+}
+
+// New create a tracer for robot clients
+func New(options ...Option) *Tracer {
+	t := &Tracer{provider: otel.GetTracerProvider()}
+	for _, opt := range options {
+		opt(t)
+	}
+
+	t.tracer = t.provider.Tracer(InstrumentationName)
+	return t
+}
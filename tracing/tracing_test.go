@@ -0,0 +1,54 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/wjiec/workrobot"
+
+	"github.com/stretchr/testify/assert"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `{"errcode":45009,"errmsg":"api freq out of limit"}`)
+	}))
+	defer server.Close()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	tracer := New(WithTracerProvider(provider))
+	c, _ := workrobot.NewClient("", workrobot.WithWebhook(server.URL+"/send?key=secret"), tracer.Instrument())
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "parent")
+	text, _ := workrobot.NewText("hello")
+	assert.Error(t, c.SendContext(ctx, text))
+	parent.End()
+
+	spans := recorder.Ended()
+	if assert.Len(t, spans, 2) {
+		span := spans[0]
+		assert.Equal(t, "workrobot.send", span.Name())
+		assert.Equal(t, parent.SpanContext().SpanID(), span.Parent().SpanID())
+
+		attrs := map[string]interface{}{}
+		for _, attr := range span.Attributes() {
+			attrs[string(attr.Key)] = attr.Value.AsInterface()
+		}
+		assert.Equal(t, "text", attrs[string(AttributeMessageType)])
+		assert.Equal(t, int64(45009), attrs[string(AttributeErrcode)])
+		assert.Equal(t, int64(1), attrs[string(AttributeAttempt)])
+		assert.NotContains(t, attrs[string(AttributeWebhook)], "secret")
+	}
+}
+
+func TestRedact(t *testing.T) {
+	assert.Equal(t, "https://example.com/send?key=REDACTED", Redact("https://example.com/send?key=secret"))
+	assert.Equal(t, "https://example.com/send", Redact("https://example.com/send"))
+}
@@ -0,0 +1,118 @@
+package vcs
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/wjiec/workrobot/internal/strutil"
+
+	"github.com/pkg/errors"
+)
+
+// githubUser represents the user of github payload
+type githubUser struct {
+	Login string `json:"login"`
+}
+
+// githubEvent represents the common fields of github events
+type githubEvent struct {
+	Action     string `json:"action"`
+	Repository struct {
+		FullName string `json:"full_name"`
+		HtmlURL  string `json:"html_url"`
+	} `json:"repository"`
+	Sender githubUser `json:"sender"`
+
+	// push
+	Ref     string `json:"ref"`
+	Compare string `json:"compare"`
+	Deleted bool   `json:"deleted"`
+	Commits []struct {
+		Id      string `json:"id"`
+		Message string `json:"message"`
+		URL     string `json:"url"`
+		Author  struct {
+			Name string `json:"name"`
+		} `json:"author"`
+	} `json:"commits"`
+
+	// pull_request
+	PullRequest *struct {
+		Title   string     `json:"title"`
+		HtmlURL string     `json:"html_url"`
+		User    githubUser `json:"user"`
+		Merged  bool       `json:"merged"`
+		Head    struct {
+			Ref string `json:"ref"`
+		} `json:"head"`
+		Base struct {
+			Ref string `json:"ref"`
+		} `json:"base"`
+	} `json:"pull_request"`
+
+	// workflow_run
+	WorkflowRun *struct {
+		Name       string     `json:"name"`
+		HeadBranch string     `json:"head_branch"`
+		Status     string     `json:"status"`
+		Conclusion string     `json:"conclusion"`
+		HtmlURL    string     `json:"html_url"`
+		Actor      githubUser `json:"actor"`
+	} `json:"workflow_run"`
+
+	// release
+	Release *struct {
+		TagName string     `json:"tag_name"`
+		Name    string     `json:"name"`
+		HtmlURL string     `json:"html_url"`
+		Author  githubUser `json:"author"`
+	} `json:"release"`
+
+	// issues
+	Issue *struct {
+		Title   string     `json:"title"`
+		HtmlURL string     `json:"html_url"`
+		User    githubUser `json:"user"`
+	} `json:"issue"`
+}
+
+// parseGitHub parse the github event by the X-GitHub-Event header, nil
+// returned when the event is ignored
+func parseGitHub(name string, body []byte) (*Event, error) {
+	var payload githubEvent
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, errors.Wrap(err, "invalid github payload")
+	}
+
+	event := &Event{Repository: payload.Repository.FullName, Author: payload.Sender.Login, Action: payload.Action}
+	switch {
+	case name == "push" && !payload.Deleted:
+		event.Kind, event.URL = EventPush, payload.Compare
+		event.Branch = strings.TrimPrefix(strings.TrimPrefix(payload.Ref, "refs/heads/"), "refs/tags/")
+		for _, commit := range payload.Commits {
+			event.Commits = append(event.Commits, &Commit{
+				Id: commit.Id, Message: commit.Message, Author: commit.Author.Name, URL: commit.URL})
+		}
+	case name == "pull_request" && payload.PullRequest != nil:
+		pr := payload.PullRequest
+		event.Kind, event.Title, event.URL = EventPullRequest, pr.Title, pr.HtmlURL
+		event.Branch, event.Target = pr.Head.Ref, pr.Base.Ref
+		if payload.Action == "closed" && pr.Merged {
+			event.Action = "merged"
+		}
+	case name == "workflow_run" && payload.WorkflowRun != nil && payload.Action == "completed":
+		run := payload.WorkflowRun
+		event.Kind, event.Title, event.URL = EventPipeline, run.Name, run.HtmlURL
+		event.Branch, event.Author, event.Action = run.HeadBranch, run.Actor.Login, run.Conclusion
+	case name == "release" && payload.Release != nil:
+		release := payload.Release
+		event.Kind, event.Title, event.URL = EventRelease, strutil.FirstOf(release.Name, release.TagName), release.HtmlURL
+		event.Branch, event.Author = release.TagName, release.Author.Login
+	case name == "issues" && payload.Issue != nil:
+		issue := payload.Issue
+		event.Kind, event.Title, event.URL = EventIssue, issue.Title, issue.HtmlURL
+	default:
+		return nil, nil
+	}
+	return event, nil
+}
@@ -0,0 +1,114 @@
+package vcs
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/wjiec/workrobot/internal/strutil"
+
+	"github.com/pkg/errors"
+)
+
+// gitlabUser represents the user of gitlab payload
+type gitlabUser struct {
+	Name     string `json:"name"`
+	Username string `json:"username"`
+}
+
+// gitlabEvent represents the common fields of gitlab events
+type gitlabEvent struct {
+	Project struct {
+		PathWithNamespace string `json:"path_with_namespace"`
+		WebURL            string `json:"web_url"`
+	} `json:"project"`
+	User gitlabUser `json:"user"`
+
+	// push
+	Ref      string `json:"ref"`
+	Before   string `json:"before"`
+	After    string `json:"after"`
+	UserName string `json:"user_username"`
+	Commits  []struct {
+		Id      string `json:"id"`
+		Message string `json:"message"`
+		URL     string `json:"url"`
+		Author  struct {
+			Name string `json:"name"`
+		} `json:"author"`
+	} `json:"commits"`
+
+	// merge_request, pipeline and issue
+	ObjectAttributes struct {
+		Id           int64  `json:"id"`
+		Title        string `json:"title"`
+		URL          string `json:"url"`
+		Action       string `json:"action"`
+		Ref          string `json:"ref"`
+		Status       string `json:"status"`
+		SourceBranch string `json:"source_branch"`
+		TargetBranch string `json:"target_branch"`
+	} `json:"object_attributes"`
+
+	// release
+	Action string `json:"action"`
+	Tag    string `json:"tag"`
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+}
+
+// gitlab actions are in present tense
+var gitlabActions = map[string]string{
+	"open": "opened", "close": "closed", "reopen": "reopened", "update": "updated",
+	"merge": "merged", "approved": "approved", "create": "created",
+}
+
+// parseGitLab parse the gitlab event by the X-Gitlab-Event header, nil
+// returned when the event is ignored
+func parseGitLab(name string, body []byte) (*Event, error) {
+	var payload gitlabEvent
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, errors.Wrap(err, "invalid gitlab payload")
+	}
+
+	attrs := payload.ObjectAttributes
+	event := &Event{Repository: payload.Project.PathWithNamespace, Author: payload.User.Username}
+	switch name {
+	case "Push Hook", "Tag Push Hook":
+		if strings.Trim(payload.After, "0") == "" { // branch or tag deleted
+			return nil, nil
+		}
+
+		event.Kind, event.Author = EventPush, payload.UserName
+		event.Branch = strings.TrimPrefix(strings.TrimPrefix(payload.Ref, "refs/heads/"), "refs/tags/")
+		event.URL = fmt.Sprintf("%s/-/compare/%s...%s", payload.Project.WebURL, payload.Before, payload.After)
+		for _, commit := range payload.Commits {
+			event.Commits = append(event.Commits, &Commit{
+				Id: commit.Id, Message: commit.Message, Author: commit.Author.Name, URL: commit.URL})
+		}
+	case "Merge Request Hook":
+		event.Kind, event.Title, event.URL = EventPullRequest, attrs.Title, attrs.URL
+		event.Branch, event.Target = attrs.SourceBranch, attrs.TargetBranch
+		event.Action = strutil.FirstOf(gitlabActions[attrs.Action], attrs.Action)
+	case "Pipeline Hook":
+		switch attrs.Status {
+		case "success", "failed", "canceled":
+		default: // running and pending pipelines are ignored
+			return nil, nil
+		}
+
+		event.Kind, event.Title = EventPipeline, fmt.Sprintf("#%d", attrs.Id)
+		event.URL = fmt.Sprintf("%s/-/pipelines/%d", payload.Project.WebURL, attrs.Id)
+		event.Branch, event.Action = attrs.Ref, attrs.Status
+	case "Release Hook":
+		event.Kind, event.Title, event.URL = EventRelease, strutil.FirstOf(payload.Name, payload.Tag), payload.URL
+		event.Branch = payload.Tag
+		event.Action = strutil.FirstOf(gitlabActions[payload.Action], payload.Action)
+	case "Issue Hook":
+		event.Kind, event.Title, event.URL = EventIssue, attrs.Title, attrs.URL
+		event.Action = strutil.FirstOf(gitlabActions[attrs.Action], attrs.Action)
+	default:
+		return nil, nil
+	}
+	return event, nil
+}
@@ -0,0 +1,356 @@
+// Package vcs implements a bridge of github and gitlab webhooks, requests
+// are verified by the signature or token, and push, pull request, pipeline,
+// release and issue events are translated into markdown messages.
+//
+// see https://docs.github.com/en/webhooks/webhook-events-and-payloads
+// and https://docs.gitlab.com/ee/user/project/integrations/webhook_events.html
+package vcs
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/wjiec/workrobot"
+	"github.com/wjiec/workrobot/internal/strutil"
+	md "github.com/wjiec/workrobot/markdown"
+
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+)
+
+const (
+	// max size of webhook request body
+	maxRequestSize = 4 * 1024 * 1024
+	// max commits listed in push message
+	maxCommitCount = 5
+	// max length of titles and commit subjects in message
+	maxTitleLength = 256
+	// max length of branch names in message
+	maxBranchLength = 128
+)
+
+// kinds of event
+const (
+	EventPush        = "push"
+	EventPullRequest = "pull_request"
+	EventPipeline    = "pipeline"
+	EventRelease     = "release"
+	EventIssue       = "issue"
+)
+
+// Commit represents a commit of push event
+type Commit struct {
+	Id      string
+	Message string
+	Author  string
+	URL     string
+}
+
+// Event represents a normalized github or gitlab event
+type Event struct {
+	Kind       string
+	Repository string
+	Author     string
+	Action     string
+	Branch     string
+	Target     string
+	Title      string
+	URL        string
+	Commits    []*Commit
+}
+
+// Route represents the robot receives events of repositories
+type Route struct {
+	Repository string
+	Events     []string
+	Client     *workrobot.Client
+}
+
+// match checks whether the route accepts the event, the repository is a
+// path pattern and empty events accepts all kind of events
+func (r *Route) match(event *Event) bool {
+	if matched, _ := path.Match(r.Repository, event.Repository); !matched {
+		return false
+	}
+	if len(r.Events) == 0 {
+		return true
+	}
+
+	for _, kind := range r.Events {
+		if kind == event.Kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Bridge represents a webhook receiver sends repository events to robots
+type Bridge struct {
+	githubSecret []byte
+	gitlabToken  string
+	routes       []*Route
+}
+
+// ServeHTTP implements http.Handler
+func (b *Bridge) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, req.Body, maxRequestSize))
+	if err != nil {
+		http.Error(w, "request body unreadable", http.StatusBadRequest)
+		return
+	}
+
+	var event *Event
+	switch {
+	case req.Header.Get("X-GitHub-Event") != "":
+		if !b.verifyGitHub(req, body) {
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+		event, err = parseGitHub(req.Header.Get("X-GitHub-Event"), body)
+	case req.Header.Get("X-Gitlab-Event") != "":
+		if !b.verifyGitLab(req) {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+		event, err = parseGitLab(req.Header.Get("X-Gitlab-Event"), body)
+	default:
+		http.Error(w, "unknown webhook source", http.StatusBadRequest)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if event == nil { // ping or unsupported events
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if err := b.Dispatch(req.Context(), event); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// verifyGitHub verify the X-Hub-Signature-256 of request
+func (b *Bridge) verifyGitHub(req *http.Request, body []byte) bool {
+	if len(b.githubSecret) == 0 {
+		return false
+	}
+
+	signature := strings.TrimPrefix(req.Header.Get("X-Hub-Signature-256"), "sha256=")
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, b.githubSecret)
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// verifyGitLab verify the X-Gitlab-Token of request
+func (b *Bridge) verifyGitLab(req *http.Request) bool {
+	if b.gitlabToken == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(req.Header.Get("X-Gitlab-Token")), []byte(b.gitlabToken)) == 1
+}
+
+// Dispatch sends the event to all matched robots, all robots are tried and
+// errors are aggregated
+func (b *Bridge) Dispatch(ctx context.Context, event *Event) (err error) {
+	var routes []*Route
+	for _, route := range b.routes {
+		if route.match(event) {
+			routes = append(routes, route)
+		}
+	}
+	if len(routes) == 0 {
+		return nil
+	}
+
+	msg, err := Render(event)
+	if err != nil {
+		return err
+	}
+
+	for _, route := range routes {
+		if se := route.Client.SendContext(ctx, msg); se != nil {
+			err = multierr.Append(err, errors.Wrapf(se, "send to route %s", route.Repository))
+		}
+	}
+	return
+}
+
+// Render renders the event into a markdown message, the titles, branches and
+// commit messages are truncated, and commits not fit are summarized
+func Render(event *Event) (*workrobot.Markdown, error) {
+	repository := md.Bold(event.Repository)
+	title := md.Bold(strutil.Truncate(event.Title, maxTitleLength))
+	branch := md.Code(strutil.Truncate(event.Branch, maxBranchLength))
+
+	var lines []md.Segment
+	var commits []md.Segment
+	switch event.Kind {
+	case EventPush:
+		if len(event.Commits) == 0 { // tags or branches without new commits
+			lines = append(lines, md.Join(" ", event.Author, "pushed", branch, "to", repository))
+			break
+		}
+
+		lines = append(lines, md.Join(" ", event.Author, "pushed",
+			md.ColorGreen(strconv.Itoa(len(event.Commits))), "commits to", branch, "of", repository))
+		for _, commit := range event.Commits {
+			commits = append(commits, md.Quote(md.Join(" ", md.Link(shortId(commit.Id), commit.URL),
+				strutil.Truncate(firstLine(commit.Message), maxTitleLength), md.ColorGray(commit.Author)).String()))
+		}
+	case EventPullRequest:
+		lines = append(lines,
+			md.Join(" ", event.Author, event.Action, "pull request", title, "in", repository),
+			md.Quote(md.Join(" → ", branch, md.Code(strutil.Truncate(event.Target, maxBranchLength))).String()))
+	case EventPipeline:
+		lines = append(lines,
+			md.Join(" ", "Pipeline", title, status(event.Action), "on", branch, "of", repository),
+			md.Quote("triggered by "+event.Author))
+	case EventRelease:
+		lines = append(lines, md.Join(" ", "Release", title, event.Action, "in", repository))
+		if event.Author != "" {
+			lines = append(lines, md.Quote("published by "+event.Author))
+		}
+	case EventIssue:
+		lines = append(lines, md.Join(" ", event.Author, event.Action, "issue", title, "in", repository))
+	default:
+		return nil, errors.Errorf("unsupported event %q", event.Kind)
+	}
+
+	var size int
+	var msg workrobot.Markdown
+	for _, line := range lines {
+		if err := msg.AddSegmentLine(line); err != nil {
+			return nil, err
+		}
+		size += len(line.String()) + 1
+	}
+
+	var link md.Segment
+	if event.URL != "" {
+		link = md.Link("View on "+hostOf(event.URL), event.URL)
+		size += len(link.String()) + 1
+	}
+
+	for i, commit := range commits {
+		more := md.ColorGray(fmt.Sprintf("... and %d more commits", len(commits)-i))
+		// keeps the space of summary unless it is the last commit
+		reserved := len(more.String()) + 1
+		if i == len(commits)-1 {
+			reserved = 0
+		}
+
+		if size += len(commit.String()) + 1; i == maxCommitCount || size+reserved > workrobot.MarkdownMessageMaxLength {
+			if err := msg.AddSegmentLine(more); err != nil {
+				return nil, err
+			}
+			break
+		}
+		if err := msg.AddSegmentLine(commit); err != nil {
+			return nil, err
+		}
+	}
+
+	if link != nil {
+		if err := msg.AddSegmentLine(link); err != nil {
+			return nil, err
+		}
+	}
+	return &msg, nil
+}
+
+// status colors the pipeline status
+func status(status string) md.Segment {
+	switch status {
+	case "success", "passed":
+		return md.ColorGreen(status)
+	case "failed", "failure", "timed_out", "canceled", "cancelled":
+		return md.ColorOrangeRed(status)
+	}
+	return md.ColorGray(status)
+}
+
+// shortId returns the abbreviated commit id
+func shortId(id string) string {
+	if len(id) > 7 {
+		return id[:7]
+	}
+	return id
+}
+
+// firstLine returns the first line of commit message
+func firstLine(message string) string {
+	if i := strings.IndexByte(message, '\n'); i >= 0 {
+		return strings.TrimSpace(message[:i])
+	}
+	return strings.TrimSpace(message)
+}
+
+// hostOf returns the host name of link
+func hostOf(link string) string {
+	if u, err := url.Parse(link); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return link
+}
+
+// Option represents additional bridge configuration
+type Option func(*Bridge)
+
+// WithGitHubSecret sets the secret verifies the signature of github webhooks,
+// github webhooks are rejected without it
+func WithGitHubSecret(secret string) Option {
+	return func(b *Bridge) {
+		b.githubSecret = []byte(secret)
+	}
+}
+
+// WithGitLabToken sets the secret token of gitlab webhooks, gitlab webhooks
+// are rejected without it
+func WithGitLabToken(token string) Option {
+	return func(b *Bridge) {
+		b.gitlabToken = token
+	}
+}
+
+// WithRoute sends events of the repositories matched the path pattern to
+// client, all kind of events are sent when events is empty
+func WithRoute(repository string, client *workrobot.Client, events ...string) Option {
+	return func(b *Bridge) {
+		b.routes = append(b.routes, &Route{Repository: repository, Events: events, Client: client})
+	}
+}
+
+// New create a bridge sends repository events to robots by routes
+func New(options ...Option) *Bridge {
+	b := &Bridge{}
+	for _, opt := range options {
+		opt(b)
+	}
+	return b
+}
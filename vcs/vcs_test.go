@@ -0,0 +1,223 @@
+package vcs
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/wjiec/workrobot"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const githubPush = `{
+  "ref": "refs/heads/main",
+  "compare": "https://github.com/wjiec/workrobot/compare/1234567...89abcde",
+  "repository": {"full_name": "wjiec/workrobot", "html_url": "https://github.com/wjiec/workrobot"},
+  "sender": {"login": "jayson"},
+  "commits": [
+    {"id": "89abcdef0123", "message": "fix typo\n\nlong description", "url": "https://github.com/wjiec/workrobot/commit/89abcdef0123", "author": {"name": "Jayson"}}
+  ]
+}`
+
+const githubPullRequest = `{
+  "action": "closed",
+  "repository": {"full_name": "wjiec/workrobot"},
+  "sender": {"login": "jayson"},
+  "pull_request": {
+    "title": "Add retry", "html_url": "https://github.com/wjiec/workrobot/pull/1", "merged": true,
+    "head": {"ref": "feature/retry"}, "base": {"ref": "main"}
+  }
+}`
+
+const gitlabPipeline = `{
+  "object_kind": "pipeline",
+  "object_attributes": {"id": 31, "ref": "main", "status": "failed"},
+  "user": {"name": "Jayson", "username": "jayson"},
+  "project": {"path_with_namespace": "group/project", "web_url": "https://gitlab.example.com/group/project"}
+}`
+
+const gitlabMergeRequest = `{
+  "object_kind": "merge_request",
+  "user": {"name": "Jayson", "username": "jayson"},
+  "project": {"path_with_namespace": "group/project", "web_url": "https://gitlab.example.com/group/project"},
+  "object_attributes": {
+    "title": "Add retry", "url": "https://gitlab.example.com/group/project/-/merge_requests/1",
+    "action": "open", "source_branch": "feature/retry", "target_branch": "main"
+  }
+}`
+
+func TestParseGitHub(t *testing.T) {
+	event, err := parseGitHub("push", []byte(githubPush))
+	if assert.NoError(t, err) && assert.NotNil(t, event) {
+		assert.Equal(t, EventPush, event.Kind)
+		assert.Equal(t, "main", event.Branch)
+		assert.Equal(t, "jayson", event.Author)
+		assert.Len(t, event.Commits, 1)
+	}
+
+	event, err = parseGitHub("pull_request", []byte(githubPullRequest))
+	if assert.NoError(t, err) && assert.NotNil(t, event) {
+		assert.Equal(t, "merged", event.Action)
+		assert.Equal(t, "feature/retry", event.Branch)
+		assert.Equal(t, "main", event.Target)
+	}
+
+	event, err = parseGitHub("ping", []byte(`{"zen": "Keep it logically awesome."}`))
+	assert.NoError(t, err)
+	assert.Nil(t, event)
+}
+
+func TestParseGitLab(t *testing.T) {
+	event, err := parseGitLab("Pipeline Hook", []byte(gitlabPipeline))
+	if assert.NoError(t, err) && assert.NotNil(t, event) {
+		assert.Equal(t, EventPipeline, event.Kind)
+		assert.Equal(t, "failed", event.Action)
+		assert.Equal(t, "https://gitlab.example.com/group/project/-/pipelines/31", event.URL)
+	}
+
+	event, err = parseGitLab("Merge Request Hook", []byte(gitlabMergeRequest))
+	if assert.NoError(t, err) && assert.NotNil(t, event) {
+		assert.Equal(t, EventPullRequest, event.Kind)
+		assert.Equal(t, "opened", event.Action)
+	}
+
+	event, err = parseGitLab("Pipeline Hook", []byte(strings.Replace(gitlabPipeline, "failed", "running", 1)))
+	assert.NoError(t, err)
+	assert.Nil(t, event)
+}
+
+func TestRender(t *testing.T) {
+	event, _ := parseGitHub("push", []byte(githubPush))
+	msg, err := Render(event)
+	if assert.NoError(t, err) {
+		content := string(msg.Message())
+		assert.Contains(t, content, "jayson pushed")
+		assert.Contains(t, content, "[89abcde](https://github.com/wjiec/workrobot/commit/89abcdef0123) fix typo")
+		assert.Contains(t, content, "[View on github.com]")
+	}
+
+	_, err = Render(&Event{Kind: "unknown"})
+	assert.Error(t, err)
+
+	msg, err = Render(&Event{Kind: EventPush, Repository: "wjiec/workrobot", Author: "jayson", Branch: "v1.0.0"})
+	if assert.NoError(t, err) {
+		assert.Contains(t, string(msg.Message()), "jayson pushed `v1.0.0` to **wjiec/workrobot**")
+	}
+
+	msg, err = Render(&Event{Kind: EventPullRequest, Repository: "wjiec/workrobot", Author: "jayson", Action: "opened",
+		Title: strings.Repeat("x", 8192), Branch: strings.Repeat("b", 8192), Target: "master"})
+	if assert.NoError(t, err) {
+		assert.NotContains(t, string(msg.Message()), strings.Repeat("x", 257))
+		assert.NotContains(t, string(msg.Message()), strings.Repeat("b", 129))
+	}
+}
+
+func TestRender_Commits(t *testing.T) {
+	event := &Event{Kind: EventPush, Repository: "wjiec/workrobot", Author: "jayson", Branch: "master",
+		URL: "https://github.com/wjiec/workrobot/compare/a...b"}
+	for i := 0; i < 20; i++ {
+		event.Commits = append(event.Commits, &Commit{Id: fmt.Sprintf("%040d", i), Author: "jayson",
+			Message: strings.Repeat("m", 8192), URL: "https://github.com/wjiec/workrobot/commit/" + strings.Repeat("c", 1024)})
+	}
+
+	msg, err := Render(event)
+	if assert.NoError(t, err) {
+		content := string(msg.Message())
+		assert.Contains(t, content, `pushed \u003cfont color=\"info\"\u003e20\u003c/font\u003e commits`)
+		assert.Contains(t, content, "more commits")
+		assert.Contains(t, content, "[View on github.com]")
+		assert.NotContains(t, content, strings.Repeat("m", 257))
+	}
+
+	event.Commits = event.Commits[:7]
+	for _, commit := range event.Commits {
+		commit.URL = "https://github.com/wjiec/workrobot/commit/" + commit.Id
+	}
+	msg, err = Render(event)
+	if assert.NoError(t, err) {
+		assert.Contains(t, string(msg.Message()), "... and 2 more commits")
+	}
+}
+
+func TestBridge_ServeHTTP(t *testing.T) {
+	var received int
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received++
+		_, _ = fmt.Fprint(w, `{"errcode":0,"errmsg":"ok"}`)
+	}))
+	defer gateway.Close()
+
+	client, _ := workrobot.NewClient("", workrobot.WithWebhook(gateway.URL))
+	server := httptest.NewServer(New(
+		WithGitHubSecret("secret"), WithGitLabToken("token"),
+		WithRoute("wjiec/*", client, EventPush),
+		WithRoute("group/project", client),
+	))
+	defer server.Close()
+
+	post := func(body string, headers map[string]string) int {
+		req, _ := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(body))
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = ioutil.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte(githubPush))
+	signature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	assert.Equal(t, http.StatusUnauthorized, post(githubPush, map[string]string{"X-GitHub-Event": "push", "X-Hub-Signature-256": "sha256=00"}))
+	assert.Equal(t, http.StatusOK, post(githubPush, map[string]string{"X-GitHub-Event": "push", "X-Hub-Signature-256": signature}))
+	assert.Equal(t, 1, received)
+
+	mac.Reset()
+	mac.Write([]byte(githubPullRequest))
+	signature = "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	assert.Equal(t, http.StatusOK, post(githubPullRequest, map[string]string{"X-GitHub-Event": "pull_request", "X-Hub-Signature-256": signature}))
+	assert.Equal(t, 1, received) // pull request is not routed
+
+	assert.Equal(t, http.StatusUnauthorized, post(gitlabPipeline, map[string]string{"X-Gitlab-Event": "Pipeline Hook", "X-Gitlab-Token": "invalid"}))
+	assert.Equal(t, http.StatusOK, post(gitlabPipeline, map[string]string{"X-Gitlab-Event": "Pipeline Hook", "X-Gitlab-Token": "token"}))
+	assert.Equal(t, 2, received)
+
+	assert.Equal(t, http.StatusBadRequest, post("{}", nil))
+}
+
+func TestBridge_Dispatch(t *testing.T) {
+	var received int
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/rejected" {
+			_, _ = fmt.Fprint(w, `{"errcode":93000,"errmsg":"invalid webhook url"}`)
+			return
+		}
+		received++
+		_, _ = fmt.Fprint(w, `{"errcode":0,"errmsg":"ok"}`)
+	}))
+	defer gateway.Close()
+
+	rejected, _ := workrobot.NewClient("", workrobot.WithWebhook(gateway.URL+"/rejected"))
+	client, _ := workrobot.NewClient("", workrobot.WithWebhook(gateway.URL))
+	bridge := New(WithRoute("wjiec/*", rejected), WithRoute("wjiec/workrobot", client))
+
+	event, _ := parseGitHub("push", []byte(githubPush))
+	err := bridge.Dispatch(context.Background(), event)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "send to route wjiec/*")
+	assert.Equal(t, 1, received)
+}
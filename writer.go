@@ -0,0 +1,277 @@
+package workrobot
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	md "github.com/wjiec/workrobot/markdown"
+)
+
+// ErrWriterClosed represents writes to a closed writer
+var ErrWriterClosed = errors.New("write to closed writer")
+
+// WriterFormat represents the message format of written lines
+type WriterFormat int
+
+const (
+	// WriterText sends lines as text messages
+	WriterText WriterFormat = iota
+	// WriterCode sends lines as inline code in markdown messages
+	WriterCode
+	// WriterQuote sends lines as quote in markdown messages
+	WriterQuote
+)
+
+// Writer represents an io.WriteCloser sends written lines to the robot, lines
+// are buffered and sent in as few messages as the length limit allows
+type Writer struct {
+	client   *Client
+	format   WriterFormat
+	interval time.Duration
+
+	// sending is held from taking the lines to sending them, so batches
+	// are sent in the order they were taken
+	sending sync.Mutex
+
+	mu      sync.Mutex
+	partial []byte
+	lines   []string
+	size    int
+	timer   *time.Timer
+	closed  bool
+	err     error
+}
+
+// Write implements io.Writer, the incomplete line is kept until the newline
+// written or the writer closed, once a background flush failed, the error is
+// returned by all later Write, Flush and Close
+func (w *Writer) Write(p []byte) (int, error) {
+	w.sending.Lock()
+	defer w.sending.Unlock()
+
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return 0, ErrWriterClosed
+	}
+	if err := w.err; err != nil {
+		w.mu.Unlock()
+		return 0, err
+	}
+
+	var batches [][]string
+	w.partial = append(w.partial, p...)
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 && len(w.partial) <= w.maxLineLength() {
+			break
+		}
+
+		// long lines are split into multiple lines
+		var line string
+		if i < 0 || i > w.maxLineLength() {
+			line, w.partial = w.cut(w.partial)
+		} else {
+			line, w.partial = string(w.partial[:i]), w.partial[i+1:]
+		}
+		if batch := w.add(strings.TrimSuffix(line, "\r")); batch != nil {
+			batches = append(batches, batch)
+		}
+	}
+	if len(w.lines) != 0 && w.timer == nil && w.interval > 0 {
+		w.timer = time.AfterFunc(w.interval, w.flushTimer)
+	}
+	w.mu.Unlock()
+
+	for _, batch := range batches {
+		if err := w.send(context.Background(), batch); err != nil {
+			return len(p), err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush sends all pending complete lines immediately
+func (w *Writer) Flush() error {
+	return w.FlushContext(context.Background())
+}
+
+// FlushContext sends all pending complete lines immediately with the context
+func (w *Writer) FlushContext(ctx context.Context) error {
+	w.sending.Lock()
+	defer w.sending.Unlock()
+
+	w.mu.Lock()
+	if err := w.err; err != nil {
+		w.mu.Unlock()
+		return err
+	}
+	batch := w.take()
+	w.mu.Unlock()
+
+	return w.send(ctx, batch)
+}
+
+// Close flush all pending lines include the incomplete one, and further
+// writes are rejected
+func (w *Writer) Close() error {
+	w.sending.Lock()
+	defer w.sending.Unlock()
+
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+
+	w.closed = true
+	var batches [][]string
+	if len(w.partial) != 0 {
+		line := strings.TrimSuffix(string(w.partial), "\r")
+		w.partial = nil
+		if batch := w.add(line); batch != nil {
+			batches = append(batches, batch)
+		}
+	}
+	batches = append(batches, w.take())
+	err := w.err
+	w.mu.Unlock()
+
+	for _, batch := range batches {
+		if sendErr := w.send(context.Background(), batch); err == nil {
+			err = sendErr
+		}
+	}
+	return err
+}
+
+// flushTimer flush the pending lines when interval elapsed
+func (w *Writer) flushTimer() {
+	if err := w.Flush(); err != nil {
+		w.mu.Lock()
+		if w.err == nil {
+			w.err = err
+		}
+		w.mu.Unlock()
+	}
+}
+
+// limit returns the max length of message content
+func (w *Writer) limit() int {
+	if w.format == WriterText {
+		return TextMessageMaxLength
+	}
+	return MarkdownMessageMaxLength
+}
+
+// render returns the line in writer format
+func (w *Writer) render(line string) string {
+	switch w.format {
+	case WriterCode:
+		return md.Code(line).String()
+	case WriterQuote:
+		return md.Quote(line).String()
+	}
+	return line
+}
+
+// maxLineLength returns the max length of line fits in a message
+func (w *Writer) maxLineLength() int {
+	return w.limit() - len(w.render(""))
+}
+
+// cut returns the longest prefix of bs fits in a message without breaking runes
+func (w *Writer) cut(bs []byte) (string, []byte) {
+	n := w.maxLineLength()
+	if n >= len(bs) {
+		return string(bs), nil
+	}
+
+	for n > 0 && !utf8.RuneStart(bs[n]) {
+		n--
+	}
+	return string(bs[:n]), bs[n:]
+}
+
+// add append the line to pending lines, the pending lines are taken and
+// returned when the line cannot fit in
+func (w *Writer) add(line string) (batch []string) {
+	rendered := w.render(line)
+	if len(w.lines) != 0 && w.size+len(rendered)+1 > w.limit() {
+		batch = w.take()
+	}
+
+	if len(w.lines) != 0 {
+		w.size++ // \n
+	}
+	w.size += len(rendered)
+	w.lines = append(w.lines, rendered)
+	return
+}
+
+// take returns and resets the pending lines
+func (w *Writer) take() []string {
+	lines := w.lines
+	w.lines, w.size = nil, 0
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+	return lines
+}
+
+// send build and send the message of lines, the sending must be held
+func (w *Writer) send(ctx context.Context, lines []string) error {
+	if len(lines) == 0 {
+		return nil
+	}
+
+	content := strings.Join(lines, "\n")
+	if w.format == WriterText {
+		text, err := NewText(content)
+		if err != nil {
+			return err
+		}
+		return w.client.SendContext(ctx, text)
+	}
+
+	var msg Markdown
+	if err := msg.RawContent(content); err != nil {
+		return err
+	}
+	return w.client.SendContext(ctx, &msg)
+}
+
+// WriterOption represents additional writer configuration
+type WriterOption func(*Writer)
+
+// WithWriterFormat sets the message format of lines, defaults to WriterText
+func WithWriterFormat(format WriterFormat) WriterOption {
+	return func(w *Writer) {
+		w.format = format
+	}
+}
+
+// WithFlushInterval sets the interval of flushing pending lines, defaults to
+// 5 seconds, and lines are only sent when the message full or writer closed
+// if the interval is 0
+func WithFlushInterval(interval time.Duration) WriterOption {
+	return func(w *Writer) {
+		w.interval = interval
+	}
+}
+
+// NewWriter create a writer sends written lines to client, it can be used
+// as the output of log or the stderr of command
+func NewWriter(client *Client, options ...WriterOption) *Writer {
+	w := &Writer{client: client, format: WriterText, interval: 5 * time.Second}
+	for _, opt := range options {
+		opt(w)
+	}
+	return w
+}
@@ -0,0 +1,145 @@
+package workrobot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newWriterGateway(t *testing.T) (*Client, func() []string) {
+	var mu sync.Mutex
+	var received []string
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bs, _ := ioutil.ReadAll(r.Body)
+
+		mu.Lock()
+		received = append(received, string(bs))
+		mu.Unlock()
+		_, _ = fmt.Fprint(w, `{"errcode":0,"errmsg":"ok"}`)
+	}))
+	t.Cleanup(gateway.Close)
+
+	client, _ := NewClient("", WithWebhook(gateway.URL))
+	return client, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), received...)
+	}
+}
+
+func TestWriter(t *testing.T) {
+	t.Run("close", func(t *testing.T) {
+		client, received := newWriterGateway(t)
+		w := NewWriter(client, WithFlushInterval(0))
+
+		_, _ = fmt.Fprint(w, "line 1\r\nline 2\nincomplete")
+		assert.Len(t, received(), 0)
+
+		assert.NoError(t, w.Close())
+		if assert.Len(t, received(), 1) {
+			assert.Contains(t, received()[0], `"content":"line 1\nline 2\nincomplete"`)
+		}
+
+		_, err := w.Write([]byte("closed"))
+		assert.Equal(t, ErrWriterClosed, err)
+	})
+
+	t.Run("split", func(t *testing.T) {
+		client, received := newWriterGateway(t)
+		w := NewWriter(client, WithWriterFormat(WriterCode), WithFlushInterval(0))
+
+		line := strings.Repeat("x", 1000)
+		for i := 0; i < 5; i++ {
+			_, _ = fmt.Fprintln(w, line)
+		}
+		assert.Len(t, received(), 1)
+
+		_, _ = fmt.Fprint(w, strings.Repeat("中", MarkdownMessageMaxLength))
+		assert.NoError(t, w.Close())
+		for _, msg := range received() {
+			assert.Contains(t, msg, `"msgtype":"markdown"`)
+		}
+		assert.True(t, len(received()) > 3)
+	})
+
+	t.Run("interval", func(t *testing.T) {
+		client, received := newWriterGateway(t)
+		w := NewWriter(client, WithWriterFormat(WriterQuote), WithFlushInterval(10*time.Millisecond))
+
+		logger := log.New(w, "", 0)
+		logger.Println("disk full")
+		logger.Println("oom")
+
+		assert.Eventually(t, func() bool { return len(received()) == 1 }, time.Second, 5*time.Millisecond)
+		assert.Contains(t, received()[0], `"content":"\u003e disk full\n\u003e oom"`)
+		assert.NoError(t, w.Close())
+	})
+
+	t.Run("order", func(t *testing.T) {
+		var mu sync.Mutex
+		var received []string
+		gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var payload struct {
+				Text struct {
+					Content string `json:"content"`
+				} `json:"text"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&payload)
+			time.Sleep(time.Millisecond)
+
+			mu.Lock()
+			received = append(received, strings.Split(payload.Text.Content, "\n")...)
+			mu.Unlock()
+			_, _ = fmt.Fprint(w, `{"errcode":0,"errmsg":"ok"}`)
+		}))
+		defer gateway.Close()
+
+		client, _ := NewClient("", WithWebhook(gateway.URL))
+		w := NewWriter(client, WithFlushInterval(time.Millisecond))
+
+		var expected []string
+		for i := 0; i < 500; i++ {
+			line := fmt.Sprintf("%04d %s", i, strings.Repeat("x", 100))
+			expected = append(expected, line)
+			_, _ = fmt.Fprintln(w, line)
+		}
+		assert.NoError(t, w.Close())
+
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, expected, received)
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = fmt.Fprint(w, `{"errcode":45009,"errmsg":"api freq out of limit"}`)
+		}))
+		defer gateway.Close()
+
+		client, _ := NewClient("", WithWebhook(gateway.URL))
+		w := NewWriter(client, WithFlushInterval(5*time.Millisecond))
+
+		_, err := fmt.Fprintln(w, "lost")
+		assert.NoError(t, err)
+		assert.Eventually(t, func() bool {
+			_, err = fmt.Fprintln(w, "rejected")
+			return err != nil
+		}, time.Second, 5*time.Millisecond)
+
+		var receipt *Receipt
+		assert.ErrorAs(t, err, &receipt)
+		_, err = fmt.Fprintln(w, "rejected")
+		assert.ErrorAs(t, err, &receipt)
+		assert.ErrorAs(t, w.Flush(), &receipt)
+		assert.ErrorAs(t, w.Close(), &receipt)
+	})
+}